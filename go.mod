@@ -4,6 +4,14 @@ go 1.14
 
 require (
 	github.com/hashicorp/terraform-plugin-sdk v1.15.0
-	github.com/roshankarande/go-vsphere v0.3.5
 	github.com/vmware/govmomi v0.23.1
+	go.opentelemetry.io/otel v0.13.0
+	go.opentelemetry.io/otel/exporters/otlp v0.13.0
+	go.opentelemetry.io/otel/exporters/stdout v0.13.0
+	go.opentelemetry.io/otel/sdk v0.13.0
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	// the grpc required by the otlp exporter no longer has grpc/naming, which the google.golang.org/api
+	// that go-getter (via terraform-plugin-sdk) asks for still imports; v0.20.0 no longer does
+	google.golang.org/api v0.20.0 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -2,9 +2,10 @@ package helper
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
-	"log"
+	"github.com/roshankarande/utils/logging"
 	"os/exec"
 	"time"
 )
@@ -50,18 +51,21 @@ func ExecuteCommand(d *schema.ResourceData, key string, marshalFunc func (d *sch
 		"StartTime"  : time.Now().UTC().Format(time.RFC3339),
 	}
 
-	log.Printf("[DEBUG] : __custom__ : [executing]%s - [cmd]%s - [env]%v ",key, cmd, m)
+	ctx := logging.WithFields(context.Background(), logging.Fields{"key": key, "cmd": cmd})
+
+	// ResourceData may hold secrets, so only its size is logged
+	logging.Debug(ctx, "__custom__ : executing", logging.Fields{"start_time": m["StartTime"], "resource_data_bytes": len(JsonSchema)})
 
 	stdout, stderr, err := ExecutePowershellCmd(cmd, m)
 
-	log.Printf("[DEBUG] : __custom__ : [result]%s - [cmd]%s - [stdout]%s, [stderr]%s, [err]%v ",key, cmd, stdout, stderr, err)
+	logging.Debug(ctx, "__custom__ : result", logging.Fields{"stdout": stdout, "stderr": stderr, "error": err})
 
 	if err != nil || stderr != "" {
-		log.Printf("[DEBUG] : __custom__ : error executing command - %s [err]%v  [stderr]%v ", cmd, err, stderr)
+		logging.Error(ctx, "__custom__ : error executing command", logging.Fields{"stderr": stderr, "error": err})
 		return fmt.Errorf("error executing command - %s [err]%v  [stderr]%v ", cmd, err, stderr)
 	}
 
-	log.Printf("[DEBUG] : __custom__ : %s executed Succesfully", key)
+	logging.Debug(ctx, "__custom__ : executed successfully", nil)
 
 	return nil
 }
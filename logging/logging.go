@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// Fields are key/value pairs attached to a log entry, such as vm, pid or command_id.
+type Fields map[string]interface{}
+
+// Logger receives every log entry of the helper, vsphere and toolbox packages.
+type Logger interface {
+	Log(level Level, msg string, fields Fields)
+}
+
+// StdLogger writes entries through the standard log package as "[LEVEL] msg key=value ...",
+// which is the format terraform picks up from providers.
+type StdLogger struct {
+	// Logger defaults to the standard logger.
+	Logger *log.Logger
+	// Level is the lowest level written. The zero value writes debug entries,
+	// which may include SOAP traces.
+	Level Level
+}
+
+func (s *StdLogger) Log(level Level, msg string, fields Fields) {
+	if level < s.Level {
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", level, msg)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+
+	if s.Logger != nil {
+		s.Logger.Print(b.String())
+	} else {
+		log.Print(b.String())
+	}
+}
+
+type discard struct{}
+
+func (discard) Log(Level, string, Fields) {}
+
+// Discard drops every entry.
+var Discard Logger = discard{}
+
+var (
+	mu     sync.RWMutex
+	logger Logger = &StdLogger{Level: LevelInfo}
+)
+
+// SetLogger replaces the logger used by all packages of this module. The default
+// writes info and above; debug entries are opt-in with SetLogger(&StdLogger{Level: LevelDebug}).
+func SetLogger(l Logger) {
+	if l == nil {
+		l = Discard
+	}
+
+	mu.Lock()
+	logger = l
+	mu.Unlock()
+}
+
+type fieldsKey struct{}
+
+// WithFields returns a context carrying fields in addition to those already in ctx,
+// to be added to every entry logged with it.
+func WithFields(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	for k, v := range FieldsFrom(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFrom returns the fields carried by ctx.
+func FieldsFrom(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+
+	f, _ := ctx.Value(fieldsKey{}).(Fields)
+	return f
+}
+
+// Log sends msg with the fields of ctx and fields to the logger.
+func Log(ctx context.Context, level Level, msg string, fields Fields) {
+	merged := Fields{}
+	for k, v := range FieldsFrom(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	mu.RLock()
+	l := logger
+	mu.RUnlock()
+
+	l.Log(level, msg, merged)
+}
+
+func Debug(ctx context.Context, msg string, fields Fields) {
+	Log(ctx, LevelDebug, msg, fields)
+}
+
+func Info(ctx context.Context, msg string, fields Fields) {
+	Log(ctx, LevelInfo, msg, fields)
+}
+
+func Warn(ctx context.Context, msg string, fields Fields) {
+	Log(ctx, LevelWarn, msg, fields)
+}
+
+func Error(ctx context.Context, msg string, fields Fields) {
+	Log(ctx, LevelError, msg, fields)
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	_ "github.com/roshankarande/utils/helper"
+	_ "github.com/roshankarande/utils/logging"
+	_ "github.com/roshankarande/utils/telemetry"
 	_ "github.com/roshankarande/utils/vsphere"
 	_ "github.com/roshankarande/utils/vsphere/guest/toolbox"
 )
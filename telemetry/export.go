@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/metric/controller/push"
+	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Config selects where Setup sends spans and metrics.
+type Config struct {
+	// Exporter is ExporterStdout or ExporterOTLP.
+	Exporter string
+	// Endpoint is the OTLP collector address. Defaults to localhost:55680.
+	Endpoint string
+	// Insecure disables TLS to the collector, which is what a local collector usually needs.
+	Insecure bool
+	// Writer is where the stdout exporter writes. Defaults to os.Stdout.
+	Writer io.Writer
+	// Interval between metric exports. Defaults to 10 seconds.
+	Interval time.Duration
+}
+
+type exporter interface {
+	exporttrace.SpanExporter
+	export.Exporter
+}
+
+// Setup installs global trace and meter providers exporting to the configured exporter. The returned
+// function flushes what has not been exported yet and stops the exporter; call it before exiting.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second * 10
+	}
+
+	var (
+		exp      exporter
+		shutdown func(context.Context) error
+	)
+
+	switch cfg.Exporter {
+	case ExporterStdout:
+		w := cfg.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+
+		e, err := stdout.NewExporter(stdout.WithWriter(w), stdout.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("creating stdout exporter: %s", err)
+		}
+
+		exp = e
+		shutdown = func(context.Context) error { return nil }
+	case ExporterOTLP:
+		opts := []otlp.ExporterOption{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlp.WithAddress(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlp.WithInsecure())
+		}
+
+		e, err := otlp.NewExporter(opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp exporter: %s", err)
+		}
+
+		exp = e
+		shutdown = e.Shutdown
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+
+	bsp := sdktrace.NewBatchSpanProcessor(exp)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(bsp))
+
+	pusher := push.New(basic.New(simple.NewWithExactDistribution(), exp), exp, push.WithPeriod(cfg.Interval))
+	pusher.Start()
+
+	global.SetTracerProvider(tp)
+	global.SetMeterProvider(pusher.MeterProvider())
+
+	return func(ctx context.Context) error {
+		// both flush on the way out
+		tp.UnregisterSpanProcessor(bsp)
+		pusher.Stop()
+
+		return shutdown(ctx)
+	}, nil
+}
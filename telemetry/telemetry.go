@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/metric"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// InstrumentationName is the name spans and metrics of the helper, vsphere and toolbox packages are reported under.
+const InstrumentationName = "github.com/roshankarande/utils"
+
+// Until a provider is installed with Setup or the otel global package, spans and metrics are no-ops.
+// The global meter delegates instruments created before that to the provider once it is set.
+var (
+	meter = metric.Must(global.Meter(InstrumentationName))
+
+	calls = meter.NewInt64Counter("vsphere.calls",
+		metric.WithDescription("Number of vSphere and guest operations"))
+	latency = meter.NewFloat64ValueRecorder("vsphere.call.duration",
+		metric.WithDescription("Duration of vSphere and guest operations"),
+		metric.WithUnit(unit.Milliseconds))
+	transferred = meter.NewInt64Counter("vsphere.transfer.bytes",
+		metric.WithDescription("Bytes transferred to and from guests"),
+		metric.WithUnit(unit.Bytes))
+)
+
+// Op is a traced and measured operation, started with Start.
+type Op struct {
+	ctx    context.Context
+	span   trace.Span
+	name   string
+	start  time.Time
+	labels []label.KeyValue
+}
+
+// Start starts a span called name and returns a context carrying it. labels are set on the span and on the
+// metrics recorded by End, so keep them low in cardinality (method names, guest family; not pids).
+func Start(ctx context.Context, name string, labels ...label.KeyValue) (context.Context, *Op) {
+	ctx, span := global.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(labels...))
+
+	return ctx, &Op{ctx: ctx, span: span, name: name, start: time.Now(), labels: labels}
+}
+
+// SetAttributes adds attributes to the span only, for values like vm or pid that are too many to label metrics with.
+func (o *Op) SetAttributes(kv ...label.KeyValue) {
+	o.span.SetAttributes(kv...)
+}
+
+// End ends the span and records the call count and latency of the operation, marking both as failed if err is not nil.
+func (o *Op) End(err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+		o.span.RecordError(o.ctx, err)
+		o.span.SetStatus(codes.Error, err.Error())
+	}
+
+	labels := append([]label.KeyValue{label.String("operation", o.name), label.String("status", status)}, o.labels...)
+
+	calls.Add(o.ctx, 1, labels...)
+	latency.Record(o.ctx, float64(time.Since(o.start))/float64(time.Millisecond), labels...)
+
+	o.span.End()
+}
+
+// Transferred records n bytes moved in direction ("upload" or "download") by the operation.
+func (o *Op) Transferred(direction string, n int64) {
+	o.span.SetAttributes(label.Int64("bytes", n))
+
+	labels := append([]label.KeyValue{label.String("operation", o.name), label.String("direction", direction)}, o.labels...)
+
+	transferred.Add(o.ctx, n, labels...)
+}
+
+// SetAttributes adds attributes to the span carried by ctx, if any.
+func SetAttributes(ctx context.Context, kv ...label.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(kv...)
+}
@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	"go.opentelemetry.io/otel/sdk/metric/controller/pull"
+	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestOpEnd(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	global.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	defer global.SetTracerProvider(trace.NoopTracerProvider())
+
+	// the global meter only delegates to the first provider set, so this is the one test installing one
+	metrics := pull.New(basic.New(simple.NewWithInexpensiveDistribution(), export.CumulativeExporter), pull.WithCachePeriod(0))
+	global.SetMeterProvider(metrics.MeterProvider())
+
+	ctx := context.Background()
+
+	_, op := Start(ctx, "test.ok", label.String("family", "windows"))
+	op.End(nil)
+
+	_, op = Start(ctx, "test.failed")
+	op.End(errors.New("access denied"))
+
+	_, op = Start(ctx, "test.failed")
+	op.End(errors.New("timeout"))
+
+	got := map[string]*exporttrace.SpanData{}
+	for _, s := range spans.GetSpans() {
+		got[s.Name] = s
+	}
+
+	if s := got["test.ok"]; s == nil || s.StatusCode != codes.Unset || len(s.MessageEvents) != 0 {
+		t.Errorf("test.ok span = %+v, want it ended without error", s)
+	}
+
+	if s := got["test.failed"]; s == nil || s.StatusCode != codes.Error || s.StatusMessage != "timeout" || len(s.MessageEvents) != 1 {
+		t.Errorf("test.failed span = %+v, want it to record the error", s)
+	}
+
+	if err := metrics.Collect(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	count := map[string]int64{}
+	err := metrics.ForEach(export.CumulativeExporter, func(r export.Record) error {
+		if r.Descriptor().Name() != "vsphere.calls" {
+			return nil
+		}
+
+		sum, err := r.Aggregation().(aggregation.Sum).Sum()
+		if err != nil {
+			return err
+		}
+
+		count[r.Labels().Encoded(label.DefaultEncoder())] = sum.AsInt64()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{
+		"family=windows,operation=test.ok,status=ok": 1,
+		"operation=test.failed,status=error":         2,
+	}
+
+	if len(count) != len(want) {
+		t.Errorf("vsphere.calls = %v, want %v", count, want)
+	}
+	for labels, n := range want {
+		if count[labels] != n {
+			t.Errorf("vsphere.calls{%s} = %d, want %d", labels, count[labels], n)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// WithCertificate loads the client certificate used for holder-of-key SAML tokens.
+// Combine it with WithSTS or WithSAMLToken.
+func WithCertificate(certFile, keyFile string) ClientOption {
+	return func(o *clientOptions) {
+		o.certFile, o.keyFile = certFile, keyFile
+	}
+}
+
+// WithSTS exchanges the username and password for a SAML token at the vCenter STS and logs in with
+// that token instead of the password. The token is holder-of-key if WithCertificate is given, bearer otherwise.
+func WithSTS() ClientOption {
+	return func(o *clientOptions) {
+		o.sts = true
+	}
+}
+
+// WithSAMLToken logs in with an already issued SAML token, bearer or holder-of-key if WithCertificate is given.
+func WithSAMLToken(token string) ClientOption {
+	return func(o *clientOptions) {
+		o.token = token
+	}
+}
+
+// WithSessionID reuses an existing authenticated session, e.g. one handed over by another tool.
+// The session cannot be renewed once it expires.
+func WithSessionID(id string) ClientOption {
+	return func(o *clientOptions) {
+		o.sessionID = id
+	}
+}
+
+func (o *clientOptions) loadCertificate(sc *soap.Client) error {
+	if o.certFile == "" {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %s", err)
+	}
+
+	sc.SetCertificate(cert)
+
+	return nil
+}
+
+// loginFunc returns how to authenticate a fresh vim25.Client given the options.
+func (o *clientOptions) loginFunc(u *url.Userinfo) func(context.Context, *vim25.Client) error {
+	switch {
+	case o.sessionID != "":
+		return func(ctx context.Context, c *vim25.Client) error {
+			return loginBySessionID(ctx, c, o.sessionID)
+		}
+	case o.token != "":
+		return func(ctx context.Context, c *vim25.Client) error {
+			return loginByToken(ctx, c, o.token)
+		}
+	case o.sts:
+		return func(ctx context.Context, c *vim25.Client) error {
+			token, err := issueToken(ctx, c, u)
+			if err != nil {
+				return err
+			}
+			return loginByToken(ctx, c, token)
+		}
+	default:
+		return func(ctx context.Context, c *vim25.Client) error {
+			return session.NewManager(c).Login(ctx, u)
+		}
+	}
+}
+
+func issueToken(ctx context.Context, vc *vim25.Client, u *url.Userinfo) (string, error) {
+	c, err := sts.NewClient(ctx, vc)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := c.Issue(ctx, sts.TokenRequest{
+		Certificate: vc.Certificate(),
+		Userinfo:    u,
+		Renewable:   true,
+		Delegatable: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("issuing token: %s", err)
+	}
+
+	return s.Token, nil
+}
+
+func loginByToken(ctx context.Context, c *vim25.Client, token string) error {
+	header := soap.Header{
+		Security: &sts.Signer{
+			Certificate: c.Certificate(),
+			Token:       token,
+		},
+	}
+
+	return session.NewManager(c).LoginByToken(c.WithHeader(ctx, header))
+}
+
+func loginBySessionID(ctx context.Context, c *vim25.Client, id string) error {
+	u := c.URL()
+	c.Client.Jar.SetCookies(u, []*http.Cookie{{Name: soap.SessionCookieName, Value: id}})
+
+	s, err := session.NewManager(c).UserSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		return fmt.Errorf("session id is not valid on %s", u.Host)
+	}
+
+	return nil
+}
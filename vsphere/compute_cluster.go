@@ -1 +1,193 @@
 package vsphere
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ComputeCluster is a cluster together with its member hosts and resource pool tree.
+type ComputeCluster struct {
+	mo.ClusterComputeResource
+
+	Hosts        []mo.HostSystem
+	ResourcePool *ResourcePoolTree
+}
+
+// ResourcePoolTree is a resource pool, or vApp, with its child pools.
+type ResourcePoolTree struct {
+	mo.ResourcePool
+
+	Children []*ResourcePoolTree
+}
+
+// Config returns the cluster configuration, nil if it could not be read.
+func (c *ComputeCluster) Config() *types.ClusterConfigInfoEx {
+	config, _ := c.ConfigurationEx.(*types.ClusterConfigInfoEx)
+	return config
+}
+
+// ClusterSummary returns the cluster summary including usage, nil if it could not be read.
+func (c *ComputeCluster) ClusterSummary() *types.ClusterComputeResourceSummary {
+	summary, _ := c.Summary.(*types.ClusterComputeResourceSummary)
+	return summary
+}
+
+// DRS returns the DRS settings of the cluster.
+func (c *ComputeCluster) DRS() *types.ClusterDrsConfigInfo {
+	if config := c.Config(); config != nil {
+		return &config.DrsConfig
+	}
+	return nil
+}
+
+// HA returns the vSphere HA settings of the cluster.
+func (c *ComputeCluster) HA() *types.ClusterDasConfigInfo {
+	if config := c.Config(); config != nil {
+		return &config.DasConfig
+	}
+	return nil
+}
+
+// EVCMode returns the current EVC mode key of the cluster, empty if EVC is disabled.
+func (c *ComputeCluster) EVCMode() string {
+	if summary := c.ClusterSummary(); summary != nil {
+		return summary.CurrentEVCModeKey
+	}
+	return ""
+}
+
+// Usage returns the CPU, memory and storage usage of the cluster, nil before vSphere 6.0.
+func (c *ComputeCluster) Usage() *types.ClusterUsageSummary {
+	if summary := c.ClusterSummary(); summary != nil {
+		return summary.UsageSummary
+	}
+	return nil
+}
+
+// Walk calls f for t and all pools below it, parents before children.
+func (t *ResourcePoolTree) Walk(f func(pool *ResourcePoolTree, depth int)) {
+	t.walk(f, 0)
+}
+
+func (t *ResourcePoolTree) walk(f func(*ResourcePoolTree, int), depth int) {
+	f(t, depth)
+	for _, child := range t.Children {
+		child.walk(f, depth+1)
+	}
+}
+
+// GetComputeClusters returns the clusters whose name matches namepattern with their hosts and resource pool
+// trees. Of opts only WithScope applies, the properties retrieved are fixed. No match is not an error.
+func GetComputeClusters(ctx context.Context, c *vim25.Client, namepattern string, opts ...InventoryOption) ([]ComputeCluster, error) {
+
+	var o inventoryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	m := view.NewManager(c)
+
+	root, err := o.root(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := m.CreateContainerView(ctx, root, []string{"ClusterComputeResource"}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer v.Destroy(ctx)
+
+	var ccrs []mo.ClusterComputeResource
+
+	err = retrieveByName(ctx, v, "ClusterComputeResource", []string{"name", "summary", "configurationEx", "host", "resourcePool", "datastore", "network"}, namepattern, &ccrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pc := property.DefaultCollector(c)
+
+	var hostRefs, poolRefs []types.ManagedObjectReference
+	for _, ccr := range ccrs {
+		hostRefs = append(hostRefs, ccr.Host...)
+		if ccr.ResourcePool != nil {
+			poolRefs = append(poolRefs, *ccr.ResourcePool)
+		}
+	}
+
+	hosts := map[types.ManagedObjectReference]mo.HostSystem{}
+
+	if len(hostRefs) != 0 {
+		var hs []mo.HostSystem
+
+		err = pc.Retrieve(ctx, hostRefs, []string{"name", "runtime", "summary"}, &hs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, h := range hs {
+			hosts[h.Reference()] = h
+		}
+	}
+
+	pools, err := getResourcePools(ctx, pc, poolRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]ComputeCluster, len(ccrs))
+
+	for i, ccr := range ccrs {
+		clusters[i].ClusterComputeResource = ccr
+
+		for _, ref := range ccr.Host {
+			if h, ok := hosts[ref]; ok {
+				clusters[i].Hosts = append(clusters[i].Hosts, h)
+			}
+		}
+
+		if ccr.ResourcePool != nil {
+			clusters[i].ResourcePool = pools[*ccr.ResourcePool]
+		}
+	}
+
+	return clusters, nil
+}
+
+// getResourcePools retrieves the pools below roots one level per round trip and links them into trees.
+func getResourcePools(ctx context.Context, pc *property.Collector, roots []types.ManagedObjectReference) (map[types.ManagedObjectReference]*ResourcePoolTree, error) {
+	pools := map[types.ManagedObjectReference]*ResourcePoolTree{}
+
+	for refs := roots; len(refs) != 0; {
+		var rps []mo.ResourcePool
+
+		err := pc.Retrieve(ctx, refs, []string{"name", "parent", "config", "runtime", "resourcePool", "vm"}, &rps)
+		if err != nil {
+			return nil, err
+		}
+
+		refs = nil
+
+		for _, rp := range rps {
+			pools[rp.Reference()] = &ResourcePoolTree{ResourcePool: rp}
+			refs = append(refs, rp.ResourcePool...)
+		}
+	}
+
+	for _, pool := range pools {
+		for _, ref := range pool.ResourcePool.ResourcePool {
+			if child, ok := pools[ref]; ok {
+				pool.Children = append(pool.Children, child)
+			}
+		}
+	}
+
+	return pools, nil
+}
@@ -2,19 +2,522 @@ package vsphere
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/roshankarande/utils/telemetry"
 	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/session/cache"
+	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/soap"
+	"go.opentelemetry.io/otel/label"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func NewClient(ctx context.Context, vSphereHost, vSphereUsername, vSpherePassword string) (*govmomi.Client, error) {
+type clientOptions struct {
+	insecure    bool
+	caFile      string
+	thumbprints map[string]string
+
+	sessionCache bool
+	sessionDir   string
+	keepAlive    time.Duration
+	retry        *RetryPolicy
+	limiter      *rate.Limiter
+	maxInFlight  int
+	trace        bool
+	telemetry    bool
+
+	certFile  string
+	keyFile   string
+	sts       bool
+	token     string
+	sessionID string
+}
+
+// configure applies the TLS settings to sc, for new clients as well as ones restored from the session cache.
+func (o *clientOptions) configure(sc *soap.Client) error {
+	if err := o.loadCertificate(sc); err != nil {
+		return err
+	}
+
+	if o.insecure {
+		return nil
+	}
+
+	if o.caFile != "" {
+		if err := sc.SetRootCAs(o.caFile); err != nil {
+			return err
+		}
+	}
+
+	t := sc.DefaultTransport()
+	t.DialTLS = (&tlsVerifier{config: t.TLSClientConfig, thumbprints: o.thumbprints, client: sc}).dialTLS
+
+	return nil
+}
+
+// ClientOption configures NewClient.
+type ClientOption func(*clientOptions)
+
+// WithInsecure disables certificate verification altogether.
+func WithInsecure() ClientOption {
+	return func(o *clientOptions) {
+		o.insecure = true
+	}
+}
+
+// WithCAFile trusts the PEM encoded CA certificates in file instead of the system roots.
+// Multiple files can be given separated by the OS path list separator.
+func WithCAFile(file string) ClientOption {
+	return func(o *clientOptions) {
+		o.caFile = file
+	}
+}
+
+// WithThumbprint pins the certificate of host, given as host or host:port, to a SHA-1 or SHA-256
+// thumbprint in the colon separated hex form used by vSphere. A pinned certificate is accepted even if
+// it does not chain to a trusted CA.
+func WithThumbprint(host, thumbprint string) ClientOption {
+	return func(o *clientOptions) {
+		if o.thumbprints == nil {
+			o.thumbprints = map[string]string{}
+		}
+		o.thumbprints[hostAddr(host)] = thumbprint
+	}
+}
+
+// NewClient logs in to vSphereHost with the username and password, or with one of WithSTS, WithSAMLToken
+// or WithSessionID. Certificates are verified against the system roots unless WithCAFile, WithThumbprint
+// or WithInsecure say otherwise. The client logs in again by itself when the session expires, and retries
+// transient failures according to DefaultRetryPolicy or WithRetry.
+func NewClient(ctx context.Context, vSphereHost, vSphereUsername, vSpherePassword string, opts ...ClientOption) (*govmomi.Client, error) {
+	ctx, op := telemetry.Start(ctx, "vsphere.NewClient")
+	c, err := newClient(ctx, vSphereHost, vSphereUsername, vSpherePassword, opts...)
+	op.End(err)
+
+	return c, err
+}
+
+func newClient(ctx context.Context, vSphereHost, vSphereUsername, vSpherePassword string, opts ...ClientOption) (*govmomi.Client, error) {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	u, err := soap.ParseURL(vSphereHost)
 	if err != nil {
 		return nil, err
 	}
 
+	telemetry.SetAttributes(ctx, label.String("host", u.Host))
+
 	u.User = url.UserPassword(vSphereUsername, vSpherePassword)
 
-	return govmomi.NewClient(ctx, u, true)
-}
\ No newline at end of file
+	login := o.loginFunc(u.User)
+
+	s := &cache.Session{
+		URL:         u,
+		DirSOAP:     o.sessionDir,
+		Insecure:    o.insecure,
+		Passthrough: !o.sessionCache,
+		LoginSOAP:   login,
+	}
+
+	vc := new(vim25.Client)
+	if err = s.Login(ctx, vc, o.configure); err != nil {
+		return nil, err
+	}
+
+	r := &reauth{roundTripper: vc.RoundTripper, login: login, client: vc}
+
+	if o.telemetry {
+		r.roundTripper = &instrumented{roundTripper: r.roundTripper}
+	}
+
+	if o.trace {
+		r.roundTripper = &tracer{roundTripper: r.roundTripper}
+	}
+
+	if o.limiter != nil || o.maxInFlight > 0 {
+		l := &limiter{roundTripper: r.roundTripper, rate: o.limiter}
+		if o.maxInFlight > 0 {
+			l.inFlight = make(chan struct{}, o.maxInFlight)
+		}
+		r.roundTripper = l
+	}
+	if o.sessionCache {
+		r.session = s
+	}
+
+	if o.keepAlive != 0 {
+		h := keepalive.NewHandlerSOAP(r.roundTripper, o.keepAlive, nil)
+		h.Start()
+		r.roundTripper = h
+	}
+
+	if o.retry == nil {
+		o.retry = &DefaultRetryPolicy
+	}
+
+	if o.retry.Attempts > 1 {
+		r.roundTripper = &retrier{roundTripper: r.roundTripper, policy: *o.retry}
+	}
+
+	vc.RoundTripper = r
+
+	return &govmomi.Client{
+		Client:         vc,
+		SessionManager: session.NewManager(vc),
+	}, nil
+}
+
+// Config describes how to connect to a vCenter. It can be loaded from the GOVC_* environment
+// variables, from a YAML or JSON file of named profiles, or from a Terraform provider block
+// (see provider.SchemaVSphereSpec).
+type Config struct {
+	Name           string `json:"name,omitempty" yaml:"name,omitempty"`
+	URL            string `json:"url" yaml:"url"`
+	Username       string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password       string `json:"password,omitempty" yaml:"password,omitempty"`
+	Insecure       bool   `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+	CAFile         string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	KnownHostsFile string `json:"known_hosts_file,omitempty" yaml:"known_hosts_file,omitempty"`
+	Thumbprint     string `json:"thumbprint,omitempty" yaml:"thumbprint,omitempty"`
+	PersistSession bool   `json:"persist_session,omitempty" yaml:"persist_session,omitempty"`
+	SessionDir     string `json:"session_dir,omitempty" yaml:"session_dir,omitempty"`
+	// KeepAlive is a duration such as "10m", empty disables keep-alive.
+	KeepAlive string `json:"keep_alive,omitempty" yaml:"keep_alive,omitempty"`
+
+	// RequestsPerSecond and MaxInFlight throttle the SOAP calls of the client, 0 means unlimited.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty" yaml:"requests_per_second,omitempty"`
+	MaxInFlight       int     `json:"max_in_flight,omitempty" yaml:"max_in_flight,omitempty"`
+
+	// SOAPTrace logs redacted SOAP bodies at debug level.
+	SOAPTrace bool `json:"soap_trace,omitempty" yaml:"soap_trace,omitempty"`
+	// Telemetry reports a span and metrics for every SOAP call, see WithTelemetry.
+	Telemetry bool `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
+
+	// Auth selects the login method: password (default), sts, token or session.
+	Auth        string `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	PrivateKey  string `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	Token       string `json:"token,omitempty" yaml:"token,omitempty"`
+	SessionID   string `json:"session_id,omitempty" yaml:"session_id,omitempty"`
+}
+
+const (
+	AuthPassword = "password"
+	AuthSTS      = "sts"
+	AuthToken    = "token"
+	AuthSession  = "session"
+)
+
+// ConfigFile is a set of named vCenter profiles.
+type ConfigFile struct {
+	Default  string             `json:"default,omitempty" yaml:"default,omitempty"`
+	Profiles map[string]*Config `json:"profiles" yaml:"profiles"`
+}
+
+const redacted = "********"
+
+// ConfigFromEnv reads the govc environment variables GOVC_URL, GOVC_USERNAME, GOVC_PASSWORD, GOVC_INSECURE,
+// GOVC_TLS_CA_CERTS, GOVC_TLS_KNOWN_HOSTS, GOVC_PERSIST_SESSION, GOVC_CERTIFICATE and GOVC_PRIVATE_KEY,
+// plus VSPHERE_KEEPALIVE, VSPHERE_AUTH, VSPHERE_TOKEN, VSPHERE_SESSION_ID and VSPHERE_TELEMETRY.
+// Credentials embedded in GOVC_URL are used unless GOVC_USERNAME or GOVC_PASSWORD are set.
+func ConfigFromEnv() (*Config, error) {
+	c := &Config{
+		URL:            os.Getenv("GOVC_URL"),
+		CAFile:         os.Getenv("GOVC_TLS_CA_CERTS"),
+		KnownHostsFile: os.Getenv("GOVC_TLS_KNOWN_HOSTS"),
+		KeepAlive:      os.Getenv("VSPHERE_KEEPALIVE"),
+		Auth:           os.Getenv("VSPHERE_AUTH"),
+		Certificate:    os.Getenv("GOVC_CERTIFICATE"),
+		PrivateKey:     os.Getenv("GOVC_PRIVATE_KEY"),
+		Token:          os.Getenv("VSPHERE_TOKEN"),
+		SessionID:      os.Getenv("VSPHERE_SESSION_ID"),
+	}
+
+	var err error
+
+	for _, b := range []struct {
+		name string
+		dst  *bool
+	}{{"GOVC_INSECURE", &c.Insecure}, {"GOVC_PERSIST_SESSION", &c.PersistSession}, {"VSPHERE_TELEMETRY", &c.Telemetry}} {
+		if v := os.Getenv(b.name); v != "" {
+			if *b.dst, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("%s: %s", b.name, err)
+			}
+		}
+	}
+
+	if c.URL != "" {
+		u, err := soap.ParseURL(c.URL)
+		if err != nil {
+			return nil, fmt.Errorf("GOVC_URL: %s", err)
+		}
+
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+
+		u.User = nil
+		c.URL = u.String()
+	}
+
+	if v := os.Getenv("GOVC_USERNAME"); v != "" {
+		c.Username = v
+	}
+
+	if v := os.Getenv("GOVC_PASSWORD"); v != "" {
+		c.Password = v
+	}
+
+	return c, c.Validate()
+}
+
+// LoadConfigFile reads profiles from a YAML or JSON file; JSON is used for files ending in .json.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f ConfigFile
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(b, &f)
+	} else {
+		err = yaml.Unmarshal(b, &f)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	for name, c := range f.Profiles {
+		if c == nil {
+			return nil, fmt.Errorf("%s: profile %q is empty", path, name)
+		}
+
+		c.Name = name
+
+		if err = c.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	return &f, nil
+}
+
+// Profile returns the named profile, or the default one if name is empty.
+func (f *ConfigFile) Profile(name string) (*Config, error) {
+	if name == "" {
+		name = f.Default
+	}
+
+	if name == "" && len(f.Profiles) == 1 {
+		for _, c := range f.Profiles {
+			return c, nil
+		}
+	}
+
+	c, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+
+	return c, nil
+}
+
+// Validate checks that c is complete and consistent.
+func (c *Config) Validate() error {
+	name := c.Name
+	if name == "" {
+		name = c.URL
+	}
+
+	if c.URL == "" {
+		return fmt.Errorf("vcenter %q: url is required", name)
+	}
+
+	if _, err := soap.ParseURL(c.URL); err != nil {
+		return fmt.Errorf("vcenter %q: url: %s", name, err)
+	}
+
+	switch c.Auth {
+	case "", AuthPassword, AuthSTS:
+		if c.Username == "" {
+			return fmt.Errorf("vcenter %q: username is required", name)
+		}
+	case AuthToken:
+		if c.Token == "" {
+			return fmt.Errorf("vcenter %q: token is required", name)
+		}
+	case AuthSession:
+		if c.SessionID == "" {
+			return fmt.Errorf("vcenter %q: session_id is required", name)
+		}
+	default:
+		return fmt.Errorf("vcenter %q: unknown auth %q", name, c.Auth)
+	}
+
+	if (c.Certificate == "") != (c.PrivateKey == "") {
+		return fmt.Errorf("vcenter %q: certificate and private_key must be given together", name)
+	}
+
+	if c.Insecure && (c.CAFile != "" || c.Thumbprint != "") {
+		return fmt.Errorf("vcenter %q: insecure cannot be combined with ca_file or thumbprint", name)
+	}
+
+	if c.RequestsPerSecond < 0 || c.MaxInFlight < 0 {
+		return fmt.Errorf("vcenter %q: requests_per_second and max_in_flight cannot be negative", name)
+	}
+
+	if c.KeepAlive != "" {
+		if _, err := time.ParseDuration(c.KeepAlive); err != nil {
+			return fmt.Errorf("vcenter %q: keep_alive: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// String returns c with the password redacted, so configs can be logged safely.
+func (c Config) String() string {
+	for _, secret := range []*string{&c.Password, &c.Token, &c.SessionID} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+
+	if u, err := url.Parse(c.URL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			c.URL = u.String()
+		}
+	}
+
+	type config Config // drop the String method
+	return fmt.Sprintf("%+v", config(c))
+}
+
+// Options translates c into NewClient options.
+func (c *Config) Options() ([]ClientOption, error) {
+	var opts []ClientOption
+
+	if c.Insecure {
+		opts = append(opts, WithInsecure())
+	}
+
+	if c.CAFile != "" {
+		opts = append(opts, WithCAFile(c.CAFile))
+	}
+
+	if c.KnownHostsFile != "" {
+		known, err := loadKnownHosts(c.KnownHostsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for host, thumbprint := range known {
+			opts = append(opts, WithThumbprint(host, thumbprint))
+		}
+	}
+
+	if c.Thumbprint != "" {
+		u, err := soap.ParseURL(c.URL)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithThumbprint(u.Host, c.Thumbprint))
+	}
+
+	if c.PersistSession {
+		opts = append(opts, WithSessionCache(c.SessionDir))
+	}
+
+	if c.KeepAlive != "" {
+		d, err := time.ParseDuration(c.KeepAlive)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithKeepAlive(d))
+	}
+
+	if c.RequestsPerSecond > 0 {
+		opts = append(opts, WithRateLimit(c.RequestsPerSecond, int(c.RequestsPerSecond)+1))
+	}
+
+	if c.MaxInFlight > 0 {
+		opts = append(opts, WithMaxInFlight(c.MaxInFlight))
+	}
+
+	if c.SOAPTrace {
+		opts = append(opts, WithSOAPTrace())
+	}
+
+	if c.Telemetry {
+		opts = append(opts, WithTelemetry())
+	}
+
+	if c.Certificate != "" {
+		opts = append(opts, WithCertificate(c.Certificate, c.PrivateKey))
+	}
+
+	switch c.Auth {
+	case AuthSTS:
+		opts = append(opts, WithSTS())
+	case AuthToken:
+		opts = append(opts, WithSAMLToken(c.Token))
+	case AuthSession:
+		opts = append(opts, WithSessionID(c.SessionID))
+	}
+
+	return opts, nil
+}
+
+// NewClient validates c and connects with it.
+func (c *Config) NewClient(ctx context.Context) (*govmomi.Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(ctx, c.URL, c.Username, c.Password, opts...)
+}
+
+// loadKnownHosts reads a govc known_hosts file, one "host thumbprint" pair per line.
+func loadKnownHosts(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	known := map[string]string{}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		e := strings.Fields(line)
+		if len(e) != 2 {
+			continue
+		}
+		known[e[0]] = e[1]
+	}
+
+	return known, nil
+}
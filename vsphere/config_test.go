@@ -0,0 +1,270 @@
+package vsphere
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() Config { return Config{Name: "vc", URL: "https://vc/sdk", Username: "admin"} }
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"no url", func(c *Config) { c.URL = "" }, "url is required"},
+		{"bad url", func(c *Config) { c.URL = "https://vc:port/sdk" }, "url:"},
+		{"no username", func(c *Config) { c.Username = "" }, "username is required"},
+		{"sts without username", func(c *Config) { c.Auth, c.Username = AuthSTS, "" }, "username is required"},
+		{"token", func(c *Config) { c.Auth, c.Username, c.Token = AuthToken, "", "<saml/>" }, ""},
+		{"token missing", func(c *Config) { c.Auth = AuthToken }, "token is required"},
+		{"session", func(c *Config) { c.Auth, c.Username, c.SessionID = AuthSession, "", "52a1" }, ""},
+		{"session missing", func(c *Config) { c.Auth = AuthSession }, "session_id is required"},
+		{"unknown auth", func(c *Config) { c.Auth = "kerberos" }, "unknown auth"},
+		{"certificate alone", func(c *Config) { c.Certificate = "cert.pem" }, "must be given together"},
+		{"insecure with ca", func(c *Config) { c.Insecure, c.CAFile = true, "ca.pem" }, "insecure cannot be combined"},
+		{"insecure with thumbprint", func(c *Config) { c.Insecure, c.Thumbprint = true, "AB:CD" }, "insecure cannot be combined"},
+		{"negative rate", func(c *Config) { c.RequestsPerSecond = -1 }, "cannot be negative"},
+		{"keep alive", func(c *Config) { c.KeepAlive = "10m" }, ""},
+		{"bad keep alive", func(c *Config) { c.KeepAlive = "often" }, "keep_alive"},
+	}
+
+	for _, tt := range tests {
+		c := valid()
+		tt.modify(&c)
+
+		err := c.Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: Validate: %s", tt.name, err)
+		case tt.wantErr != "" && err == nil:
+			t.Errorf("%s: Validate accepted %+v", tt.name, c)
+		case err != nil && !strings.Contains(err.Error(), tt.wantErr):
+			t.Errorf("%s: Validate = %q, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	c := Config{
+		URL:       "https://admin:url-secret@vc/sdk",
+		Username:  "admin",
+		Password:  "pass-secret",
+		Token:     "token-secret",
+		SessionID: "session-secret",
+	}
+
+	s := c.String()
+
+	for _, secret := range []string{"url-secret", "pass-secret", "token-secret", "session-secret"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("String() = %s, leaks %s", s, secret)
+		}
+	}
+
+	if !strings.Contains(s, "admin") || !strings.Contains(s, redacted) {
+		t.Errorf("String() = %s, want the username and redacted secrets", s)
+	}
+
+	if c.Password != "pass-secret" || !strings.Contains(c.URL, "url-secret") {
+		t.Error("String() modified the config")
+	}
+
+	if s = (Config{URL: "https://vc/sdk"}).String(); strings.Contains(s, redacted) {
+		t.Errorf("String() = %s, redacts empty secrets", s)
+	}
+}
+
+// configEnv are the variables ConfigFromEnv reads.
+var configEnv = []string{
+	"GOVC_URL", "GOVC_USERNAME", "GOVC_PASSWORD", "GOVC_INSECURE", "GOVC_TLS_CA_CERTS", "GOVC_TLS_KNOWN_HOSTS",
+	"GOVC_PERSIST_SESSION", "GOVC_CERTIFICATE", "GOVC_PRIVATE_KEY", "VSPHERE_KEEPALIVE", "VSPHERE_AUTH",
+	"VSPHERE_TOKEN", "VSPHERE_SESSION_ID", "VSPHERE_TELEMETRY",
+}
+
+// setConfigEnv replaces the variables ConfigFromEnv reads with env and returns a func restoring them.
+func setConfigEnv(t *testing.T, env map[string]string) func() {
+	saved := map[string]*string{}
+
+	for _, name := range configEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = &v
+		} else {
+			saved[name] = nil
+		}
+
+		var err error
+		if v, ok := env[name]; ok {
+			err = os.Setenv(name, v)
+		} else {
+			err = os.Unsetenv(name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return func() {
+		for name, v := range saved {
+			if v == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *v)
+			}
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr string
+	}{
+		{
+			name: "credentials in url",
+			env:  map[string]string{"GOVC_URL": "https://admin:secret@vc/sdk"},
+			want: Config{URL: "https://vc/sdk", Username: "admin", Password: "secret"},
+		},
+		{
+			name: "credentials override url",
+			env:  map[string]string{"GOVC_URL": "https://admin:secret@vc/sdk", "GOVC_USERNAME": "ops", "GOVC_PASSWORD": "other"},
+			want: Config{URL: "https://vc/sdk", Username: "ops", Password: "other"},
+		},
+		{
+			name: "all variables",
+			env: map[string]string{
+				"GOVC_URL":             "vc",
+				"GOVC_USERNAME":        "admin",
+				"GOVC_TLS_CA_CERTS":    "ca.pem",
+				"GOVC_TLS_KNOWN_HOSTS": "known_hosts",
+				"GOVC_PERSIST_SESSION": "true",
+				"GOVC_CERTIFICATE":     "cert.pem",
+				"GOVC_PRIVATE_KEY":     "key.pem",
+				"VSPHERE_KEEPALIVE":    "10m",
+				"VSPHERE_AUTH":         AuthSTS,
+				"VSPHERE_TELEMETRY":    "1",
+			},
+			want: Config{
+				URL:            "https://vc/sdk",
+				Username:       "admin",
+				CAFile:         "ca.pem",
+				KnownHostsFile: "known_hosts",
+				PersistSession: true,
+				Certificate:    "cert.pem",
+				PrivateKey:     "key.pem",
+				KeepAlive:      "10m",
+				Auth:           AuthSTS,
+				Telemetry:      true,
+			},
+		},
+		{
+			name: "insecure",
+			env:  map[string]string{"GOVC_URL": "https://vc/sdk", "GOVC_USERNAME": "admin", "GOVC_INSECURE": "1"},
+			want: Config{URL: "https://vc/sdk", Username: "admin", Insecure: true},
+		},
+		{
+			name: "session",
+			env:  map[string]string{"GOVC_URL": "https://vc/sdk", "VSPHERE_AUTH": AuthSession, "VSPHERE_SESSION_ID": "52a1"},
+			want: Config{URL: "https://vc/sdk", Auth: AuthSession, SessionID: "52a1"},
+		},
+		{name: "bad bool", env: map[string]string{"GOVC_URL": "https://vc/sdk", "GOVC_INSECURE": "maybe"}, wantErr: "GOVC_INSECURE"},
+		{name: "bad url", env: map[string]string{"GOVC_URL": "https://vc:port/sdk"}, wantErr: "GOVC_URL"},
+		{name: "no url", wantErr: "url is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setConfigEnv(t, tt.env)()
+
+			c, err := ConfigFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ConfigFromEnv error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ConfigFromEnv: %s", err)
+			}
+
+			if !reflect.DeepEqual(*c, tt.want) {
+				t.Errorf("ConfigFromEnv = %+v, want %+v", *c, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vsphere-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file     string
+		content  string
+		profiles []string
+		wantErr  string
+	}{
+		{"profiles.yaml", "default: prod\nprofiles:\n  prod:\n    url: https://vc1/sdk\n    username: admin\n  lab:\n    url: https://vc2/sdk\n    auth: token\n    token: t\n", []string{"lab", "prod"}, ""},
+		{"profiles.json", `{"profiles": {"prod": {"url": "https://vc1/sdk", "username": "admin"}}}`, []string{"prod"}, ""},
+		{"upper.JSON", `{"profiles": {"prod": {"url": "https://vc1/sdk", "username": "admin"}}}`, []string{"prod"}, ""},
+		{"bad.yaml", "profiles: [", nil, "bad.yaml"},
+		{"empty.yaml", "profiles:\n  prod:\n", nil, `profile "prod" is empty`},
+		{"invalid.yaml", "profiles:\n  prod:\n    url: https://vc1/sdk\n", nil, `vcenter "prod": username is required`},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err = ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := LoadConfigFile(path)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: LoadConfigFile error = %v, want %q", tt.file, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: LoadConfigFile: %s", tt.file, err)
+			continue
+		}
+
+		for _, name := range tt.profiles {
+			c, err := f.Profile(name)
+			if err != nil {
+				t.Errorf("%s: Profile(%q): %s", tt.file, name, err)
+			} else if c.Name != name {
+				t.Errorf("%s: profile %q named %q", tt.file, name, c.Name)
+			}
+		}
+
+		if len(f.Profiles) != len(tt.profiles) {
+			t.Errorf("%s: %d profiles, want %d", tt.file, len(f.Profiles), len(tt.profiles))
+		}
+
+		// the default profile, or the only one
+		if c, err := f.Profile(""); err != nil {
+			t.Errorf("%s: default profile: %s", tt.file, err)
+		} else if c.Name != "prod" {
+			t.Errorf("%s: default profile is %q, want prod", tt.file, c.Name)
+		}
+	}
+
+	if _, err = LoadConfigFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadConfigFile read a missing file")
+	}
+}
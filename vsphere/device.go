@@ -0,0 +1,307 @@
+package vsphere
+
+import (
+	"strings"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Disk backing types.
+const (
+	DiskBackingFlat           = "flat"
+	DiskBackingSparse         = "sparse"
+	DiskBackingSeSparse       = "seSparse"
+	DiskBackingRDM            = "rdm"
+	DiskBackingRaw            = "raw"
+	DiskBackingPartitionedRaw = "partitionedRaw"
+	DiskBackingPMem           = "pmem"
+	DiskBackingOther          = "other"
+)
+
+// CD-ROM backing types.
+const (
+	CDROMBackingISO               = "iso"
+	CDROMBackingHost              = "host"
+	CDROMBackingPassthrough       = "passthrough"
+	CDROMBackingRemoteAtapi       = "remoteAtapi"
+	CDROMBackingRemotePassthrough = "remotePassthrough"
+	CDROMBackingOther             = "other"
+)
+
+// Device holds what all virtual devices have in common.
+type Device struct {
+	Key int32 `json:"key" yaml:"key"`
+	// Name is the stable name govc uses, e.g. disk-1000-0 or ethernet-0.
+	Name string `json:"name" yaml:"name"`
+	// Type is the govc device type, e.g. disk, cdrom, ethernet, pvscsi or lsilogic-sas.
+	Type          string `json:"type" yaml:"type"`
+	Label         string `json:"label,omitempty" yaml:"label,omitempty"`
+	Summary       string `json:"summary,omitempty" yaml:"summary,omitempty"`
+	ControllerKey int32  `json:"controller_key,omitempty" yaml:"controller_key,omitempty"`
+	UnitNumber    *int32 `json:"unit_number,omitempty" yaml:"unit_number,omitempty"`
+	// Connected and StartConnected are only set for connectable devices.
+	Connected      *bool `json:"connected,omitempty" yaml:"connected,omitempty"`
+	StartConnected *bool `json:"start_connected,omitempty" yaml:"start_connected,omitempty"`
+}
+
+type Disk struct {
+	Device `yaml:",inline"`
+
+	CapacityBytes int64 `json:"capacity_bytes" yaml:"capacity_bytes"`
+	// BackingType is one of the DiskBacking constants.
+	BackingType string                        `json:"backing_type" yaml:"backing_type"`
+	FileName    string                        `json:"file_name,omitempty" yaml:"file_name,omitempty"`
+	Datastore   *types.ManagedObjectReference `json:"datastore,omitempty" yaml:"datastore,omitempty"`
+	DiskMode    string                        `json:"disk_mode,omitempty" yaml:"disk_mode,omitempty"`
+	UUID        string                        `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	Sharing     string                        `json:"sharing,omitempty" yaml:"sharing,omitempty"`
+	// ThinProvisioned and EagerlyScrub are only known for flat disks.
+	ThinProvisioned *bool `json:"thin_provisioned,omitempty" yaml:"thin_provisioned,omitempty"`
+	EagerlyScrub    *bool `json:"eagerly_scrub,omitempty" yaml:"eagerly_scrub,omitempty"`
+	// CompatibilityMode, LunUUID and DeviceName are set for raw device mappings.
+	CompatibilityMode string `json:"compatibility_mode,omitempty" yaml:"compatibility_mode,omitempty"`
+	LunUUID           string `json:"lun_uuid,omitempty" yaml:"lun_uuid,omitempty"`
+	DeviceName        string `json:"device_name,omitempty" yaml:"device_name,omitempty"`
+}
+
+type Controller struct {
+	Device `yaml:",inline"`
+
+	BusNumber int32 `json:"bus_number" yaml:"bus_number"`
+	// SharedBus is the SCSI bus sharing mode, empty for other controllers.
+	SharedBus string `json:"shared_bus,omitempty" yaml:"shared_bus,omitempty"`
+	// Devices are the keys of the devices attached to the controller.
+	Devices []int32 `json:"devices,omitempty" yaml:"devices,omitempty"`
+}
+
+type NIC struct {
+	Device `yaml:",inline"`
+
+	// AdapterType is vmxnet3, vmxnet2, e1000, e1000e, pcnet32, sriov or vmxnet3vrdma.
+	AdapterType string `json:"adapter_type" yaml:"adapter_type"`
+	MacAddress  string `json:"mac_address,omitempty" yaml:"mac_address,omitempty"`
+	AddressType string `json:"address_type,omitempty" yaml:"address_type,omitempty"`
+	// Network is the name of a standard port group, Network its MoRef.
+	NetworkName string                        `json:"network_name,omitempty" yaml:"network_name,omitempty"`
+	Network     *types.ManagedObjectReference `json:"network,omitempty" yaml:"network,omitempty"`
+	// PortgroupKey and SwitchUUID are set for distributed port groups.
+	PortgroupKey string `json:"portgroup_key,omitempty" yaml:"portgroup_key,omitempty"`
+	SwitchUUID   string `json:"switch_uuid,omitempty" yaml:"switch_uuid,omitempty"`
+	// OpaqueNetworkID is set for NSX opaque networks.
+	OpaqueNetworkID string `json:"opaque_network_id,omitempty" yaml:"opaque_network_id,omitempty"`
+}
+
+type CDROM struct {
+	Device `yaml:",inline"`
+
+	// BackingType is one of the CDROMBacking constants.
+	BackingType string `json:"backing_type" yaml:"backing_type"`
+	// FileName is the ISO path for iso backings, DeviceName the host device otherwise.
+	FileName   string `json:"file_name,omitempty" yaml:"file_name,omitempty"`
+	DeviceName string `json:"device_name,omitempty" yaml:"device_name,omitempty"`
+}
+
+type USBDevice struct {
+	Device `yaml:",inline"`
+
+	DeviceName string   `json:"device_name,omitempty" yaml:"device_name,omitempty"`
+	Vendor     int32    `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Product    int32    `json:"product,omitempty" yaml:"product,omitempty"`
+	Family     []string `json:"family,omitempty" yaml:"family,omitempty"`
+	Speed      []string `json:"speed,omitempty" yaml:"speed,omitempty"`
+}
+
+// DeviceInventory is the device list of a VM sorted into typed devices. Devices not covered by a more
+// specific type, e.g. floppies, serial ports or video cards, are listed in Other.
+type DeviceInventory struct {
+	Disks       []Disk       `json:"disks,omitempty" yaml:"disks,omitempty"`
+	Controllers []Controller `json:"controllers,omitempty" yaml:"controllers,omitempty"`
+	NICs        []NIC        `json:"nics,omitempty" yaml:"nics,omitempty"`
+	CDROMs      []CDROM      `json:"cdroms,omitempty" yaml:"cdroms,omitempty"`
+	USB         []USBDevice  `json:"usb,omitempty" yaml:"usb,omitempty"`
+	Other       []Device     `json:"other,omitempty" yaml:"other,omitempty"`
+}
+
+func newDevice(l object.VirtualDeviceList, d types.BaseVirtualDevice) Device {
+	vd := d.GetVirtualDevice()
+
+	dev := Device{
+		Key:           vd.Key,
+		Name:          l.Name(d),
+		Type:          l.Type(d),
+		ControllerKey: vd.ControllerKey,
+		UnitNumber:    vd.UnitNumber,
+	}
+
+	if vd.DeviceInfo != nil {
+		info := vd.DeviceInfo.GetDescription()
+		dev.Label, dev.Summary = info.Label, info.Summary
+	}
+
+	if c := vd.Connectable; c != nil {
+		connected, start := c.Connected, c.StartConnected
+		dev.Connected, dev.StartConnected = &connected, &start
+	}
+
+	return dev
+}
+
+func newDisk(dev Device, d *types.VirtualDisk) Disk {
+	disk := Disk{Device: dev, CapacityBytes: d.CapacityInBytes, BackingType: DiskBackingOther}
+
+	if disk.CapacityBytes == 0 {
+		disk.CapacityBytes = d.CapacityInKB * 1024
+	}
+
+	if b, ok := d.Backing.(types.BaseVirtualDeviceFileBackingInfo); ok {
+		fb := b.GetVirtualDeviceFileBackingInfo()
+		disk.FileName, disk.Datastore = fb.FileName, fb.Datastore
+	}
+
+	switch b := d.Backing.(type) {
+	case *types.VirtualDiskFlatVer2BackingInfo:
+		disk.BackingType = DiskBackingFlat
+		disk.DiskMode, disk.UUID, disk.Sharing = b.DiskMode, b.Uuid, b.Sharing
+		disk.ThinProvisioned, disk.EagerlyScrub = b.ThinProvisioned, b.EagerlyScrub
+	case *types.VirtualDiskFlatVer1BackingInfo:
+		disk.BackingType = DiskBackingFlat
+		disk.DiskMode = b.DiskMode
+	case *types.VirtualDiskSparseVer2BackingInfo:
+		disk.BackingType = DiskBackingSparse
+		disk.DiskMode, disk.UUID = b.DiskMode, b.Uuid
+	case *types.VirtualDiskSparseVer1BackingInfo:
+		disk.BackingType = DiskBackingSparse
+		disk.DiskMode = b.DiskMode
+	case *types.VirtualDiskSeSparseBackingInfo:
+		disk.BackingType = DiskBackingSeSparse
+		disk.DiskMode, disk.UUID = b.DiskMode, b.Uuid
+	case *types.VirtualDiskRawDiskMappingVer1BackingInfo:
+		disk.BackingType = DiskBackingRDM
+		disk.DiskMode, disk.UUID, disk.Sharing = b.DiskMode, b.Uuid, b.Sharing
+		disk.CompatibilityMode, disk.LunUUID, disk.DeviceName = b.CompatibilityMode, b.LunUuid, b.DeviceName
+	case *types.VirtualDiskPartitionedRawDiskVer2BackingInfo:
+		disk.BackingType = DiskBackingPartitionedRaw
+		disk.FileName, disk.UUID, disk.DeviceName = b.DescriptorFileName, b.Uuid, b.DeviceName
+	case *types.VirtualDiskRawDiskVer2BackingInfo:
+		disk.BackingType = DiskBackingRaw
+		disk.FileName, disk.UUID, disk.DeviceName = b.DescriptorFileName, b.Uuid, b.DeviceName
+	case *types.VirtualDiskLocalPMemBackingInfo:
+		disk.BackingType = DiskBackingPMem
+		disk.DiskMode, disk.UUID = b.DiskMode, b.Uuid
+	}
+
+	return disk
+}
+
+func nicAdapterType(d types.BaseVirtualDevice) string {
+	switch d.(type) {
+	case *types.VirtualVmxnet3Vrdma:
+		return "vmxnet3vrdma"
+	case *types.VirtualVmxnet3:
+		return "vmxnet3"
+	case *types.VirtualVmxnet2:
+		return "vmxnet2"
+	case *types.VirtualE1000e:
+		return "e1000e"
+	case *types.VirtualE1000:
+		return "e1000"
+	case *types.VirtualPCNet32:
+		return "pcnet32"
+	case *types.VirtualSriovEthernetCard:
+		return "sriov"
+	default:
+		return strings.ToLower(strings.TrimPrefix(object.VirtualDeviceList{}.TypeName(d), "Virtual"))
+	}
+}
+
+func newNIC(dev Device, d types.BaseVirtualEthernetCard) NIC {
+	card := d.GetVirtualEthernetCard()
+
+	nic := NIC{
+		Device:      dev,
+		AdapterType: nicAdapterType(d.(types.BaseVirtualDevice)),
+		MacAddress:  card.MacAddress,
+		AddressType: card.AddressType,
+	}
+
+	switch b := card.Backing.(type) {
+	case *types.VirtualEthernetCardNetworkBackingInfo:
+		nic.NetworkName, nic.Network = b.DeviceName, b.Network
+	case *types.VirtualEthernetCardDistributedVirtualPortBackingInfo:
+		nic.PortgroupKey, nic.SwitchUUID = b.Port.PortgroupKey, b.Port.SwitchUuid
+	case *types.VirtualEthernetCardOpaqueNetworkBackingInfo:
+		nic.OpaqueNetworkID = b.OpaqueNetworkId
+	}
+
+	return nic
+}
+
+func newCDROM(dev Device, d *types.VirtualCdrom) CDROM {
+	cdrom := CDROM{Device: dev, BackingType: CDROMBackingOther}
+
+	switch b := d.Backing.(type) {
+	case *types.VirtualCdromIsoBackingInfo:
+		cdrom.BackingType, cdrom.FileName = CDROMBackingISO, b.FileName
+	case *types.VirtualCdromAtapiBackingInfo:
+		cdrom.BackingType, cdrom.DeviceName = CDROMBackingHost, b.DeviceName
+	case *types.VirtualCdromPassthroughBackingInfo:
+		cdrom.BackingType, cdrom.DeviceName = CDROMBackingPassthrough, b.DeviceName
+	case *types.VirtualCdromRemoteAtapiBackingInfo:
+		cdrom.BackingType, cdrom.DeviceName = CDROMBackingRemoteAtapi, b.DeviceName
+	case *types.VirtualCdromRemotePassthroughBackingInfo:
+		cdrom.BackingType, cdrom.DeviceName = CDROMBackingRemotePassthrough, b.DeviceName
+	}
+
+	return cdrom
+}
+
+func newUSBDevice(dev Device, d *types.VirtualUSB) USBDevice {
+	usb := USBDevice{Device: dev, Vendor: d.Vendor, Product: d.Product, Family: d.Family, Speed: d.Speed}
+
+	connected := d.Connected
+	usb.Connected = &connected
+
+	switch b := d.Backing.(type) {
+	case *types.VirtualUSBUSBBackingInfo:
+		usb.DeviceName = b.DeviceName
+	case *types.VirtualUSBRemoteHostBackingInfo:
+		usb.DeviceName = b.DeviceName
+	case *types.VirtualUSBRemoteClientBackingInfo:
+		usb.DeviceName = b.Hostname
+	}
+
+	return usb
+}
+
+// NewDeviceInventory sorts devices into a DeviceInventory. Every backing type is handled, unknown ones
+// are reported as other rather than causing a panic.
+func NewDeviceInventory(devices object.VirtualDeviceList) *DeviceInventory {
+	var inv DeviceInventory
+
+	for _, d := range devices {
+		dev := newDevice(devices, d)
+
+		switch x := d.(type) {
+		case *types.VirtualDisk:
+			inv.Disks = append(inv.Disks, newDisk(dev, x))
+		case types.BaseVirtualEthernetCard:
+			inv.NICs = append(inv.NICs, newNIC(dev, x))
+		case *types.VirtualCdrom:
+			inv.CDROMs = append(inv.CDROMs, newCDROM(dev, x))
+		case *types.VirtualUSB:
+			inv.USB = append(inv.USB, newUSBDevice(dev, x))
+		case types.BaseVirtualController:
+			c := x.GetVirtualController()
+			ctrl := Controller{Device: dev, BusNumber: c.BusNumber, Devices: c.Device}
+			if scsi, ok := d.(types.BaseVirtualSCSIController); ok {
+				ctrl.SharedBus = string(scsi.GetVirtualSCSIController().SharedBus)
+			}
+			inv.Controllers = append(inv.Controllers, ctrl)
+		default:
+			inv.Other = append(inv.Other, dev)
+		}
+	}
+
+	return &inv
+}
@@ -0,0 +1,194 @@
+package vsphere
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestNewDeviceInventoryDisks(t *testing.T) {
+	ds := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}
+	file := types.VirtualDeviceFileBackingInfo{FileName: "[ds1] vm/vm.vmdk", Datastore: &ds}
+	thin := true
+
+	tests := []struct {
+		name    string
+		backing types.BaseVirtualDeviceBackingInfo
+		want    Disk
+	}{
+		{"flat v2", &types.VirtualDiskFlatVer2BackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "persistent", Uuid: "u1", Sharing: "sharingNone", ThinProvisioned: &thin},
+			Disk{BackingType: DiskBackingFlat, FileName: file.FileName, Datastore: &ds, DiskMode: "persistent", UUID: "u1", Sharing: "sharingNone", ThinProvisioned: &thin}},
+		{"flat v1", &types.VirtualDiskFlatVer1BackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "persistent"},
+			Disk{BackingType: DiskBackingFlat, FileName: file.FileName, Datastore: &ds, DiskMode: "persistent"}},
+		{"sparse v2", &types.VirtualDiskSparseVer2BackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "independent_persistent", Uuid: "u2"},
+			Disk{BackingType: DiskBackingSparse, FileName: file.FileName, Datastore: &ds, DiskMode: "independent_persistent", UUID: "u2"}},
+		{"sparse v1", &types.VirtualDiskSparseVer1BackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "persistent"},
+			Disk{BackingType: DiskBackingSparse, FileName: file.FileName, Datastore: &ds, DiskMode: "persistent"}},
+		{"sesparse", &types.VirtualDiskSeSparseBackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "persistent", Uuid: "u3"},
+			Disk{BackingType: DiskBackingSeSparse, FileName: file.FileName, Datastore: &ds, DiskMode: "persistent", UUID: "u3"}},
+		{"rdm", &types.VirtualDiskRawDiskMappingVer1BackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "persistent", Uuid: "u4", Sharing: "sharingMultiWriter",
+			CompatibilityMode: "physicalMode", LunUuid: "lun1", DeviceName: "/vmfs/devices/disks/naa.1"},
+			Disk{BackingType: DiskBackingRDM, FileName: file.FileName, Datastore: &ds, DiskMode: "persistent", UUID: "u4", Sharing: "sharingMultiWriter",
+				CompatibilityMode: "physicalMode", LunUUID: "lun1", DeviceName: "/vmfs/devices/disks/naa.1"}},
+		{"partitioned raw", &types.VirtualDiskPartitionedRawDiskVer2BackingInfo{VirtualDiskRawDiskVer2BackingInfo: types.VirtualDiskRawDiskVer2BackingInfo{
+			VirtualDeviceDeviceBackingInfo: types.VirtualDeviceDeviceBackingInfo{DeviceName: "/dev/sdb"}, DescriptorFileName: "[ds1] vm/raw.vmdk", Uuid: "u5"}},
+			Disk{BackingType: DiskBackingPartitionedRaw, FileName: "[ds1] vm/raw.vmdk", UUID: "u5", DeviceName: "/dev/sdb"}},
+		{"raw", &types.VirtualDiskRawDiskVer2BackingInfo{VirtualDeviceDeviceBackingInfo: types.VirtualDeviceDeviceBackingInfo{DeviceName: "/dev/sdc"}, DescriptorFileName: "[ds1] vm/raw2.vmdk", Uuid: "u6"},
+			Disk{BackingType: DiskBackingRaw, FileName: "[ds1] vm/raw2.vmdk", UUID: "u6", DeviceName: "/dev/sdc"}},
+		{"pmem", &types.VirtualDiskLocalPMemBackingInfo{VirtualDeviceFileBackingInfo: file, DiskMode: "persistent", Uuid: "u7"},
+			Disk{BackingType: DiskBackingPMem, FileName: file.FileName, Datastore: &ds, DiskMode: "persistent", UUID: "u7"}},
+		{"unknown", &types.VirtualDeviceFileBackingInfo{FileName: "[ds1] vm/other.vmdk"},
+			Disk{BackingType: DiskBackingOther, FileName: "[ds1] vm/other.vmdk"}},
+		{"none", nil, Disk{BackingType: DiskBackingOther}},
+	}
+
+	for _, tt := range tests {
+		unit := int32(0)
+		d := &types.VirtualDisk{CapacityInKB: 1024}
+		d.Key, d.ControllerKey, d.UnitNumber, d.Backing = 2000, 1000, &unit, tt.backing
+
+		inv := NewDeviceInventory(object.VirtualDeviceList{d})
+		if len(inv.Disks) != 1 {
+			t.Errorf("%s: %d disks, want 1", tt.name, len(inv.Disks))
+			continue
+		}
+
+		got := inv.Disks[0]
+		if got.Key != 2000 || got.Type != "disk" || got.CapacityBytes != 1<<20 {
+			t.Errorf("%s: device = %+v", tt.name, got.Device)
+		}
+
+		got.Device = Disk{}.Device
+		got.CapacityBytes = 0
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: disk = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewDeviceInventoryNICs(t *testing.T) {
+	network := types.ManagedObjectReference{Type: "Network", Value: "network-1"}
+
+	backings := []struct {
+		name    string
+		backing types.BaseVirtualDeviceBackingInfo
+		want    NIC
+	}{
+		{"standard", &types.VirtualEthernetCardNetworkBackingInfo{VirtualDeviceDeviceBackingInfo: types.VirtualDeviceDeviceBackingInfo{DeviceName: "VM Network"}, Network: &network},
+			NIC{NetworkName: "VM Network", Network: &network}},
+		{"distributed", &types.VirtualEthernetCardDistributedVirtualPortBackingInfo{Port: types.DistributedVirtualSwitchPortConnection{PortgroupKey: "dvportgroup-1", SwitchUuid: "50 2a"}},
+			NIC{PortgroupKey: "dvportgroup-1", SwitchUUID: "50 2a"}},
+		{"opaque", &types.VirtualEthernetCardOpaqueNetworkBackingInfo{OpaqueNetworkId: "ls-1", OpaqueNetworkType: "nsx.LogicalSwitch"},
+			NIC{OpaqueNetworkID: "ls-1"}},
+		{"none", nil, NIC{}},
+	}
+
+	adapters := []struct {
+		card types.BaseVirtualEthernetCard
+		want string
+	}{
+		{&types.VirtualVmxnet3{}, "vmxnet3"},
+		{&types.VirtualVmxnet3Vrdma{}, "vmxnet3vrdma"},
+		{&types.VirtualVmxnet2{}, "vmxnet2"},
+		{&types.VirtualE1000{}, "e1000"},
+		{&types.VirtualE1000e{}, "e1000e"},
+		{&types.VirtualPCNet32{}, "pcnet32"},
+		{&types.VirtualSriovEthernetCard{}, "sriov"},
+	}
+
+	for _, a := range adapters {
+		for _, b := range backings {
+			card := a.card.GetVirtualEthernetCard()
+			card.Key, card.MacAddress, card.AddressType, card.Backing = 4000, "00:50:56:00:00:01", "assigned", b.backing
+			card.Connectable = &types.VirtualDeviceConnectInfo{Connected: true}
+
+			inv := NewDeviceInventory(object.VirtualDeviceList{a.card.(types.BaseVirtualDevice)})
+			if len(inv.NICs) != 1 {
+				t.Errorf("%s %s: %d nics, want 1", a.want, b.name, len(inv.NICs))
+				continue
+			}
+
+			got := inv.NICs[0]
+			if got.AdapterType != a.want || got.MacAddress != card.MacAddress || got.AddressType != "assigned" {
+				t.Errorf("%s %s: nic = %+v", a.want, b.name, got)
+			}
+
+			if got.Connected == nil || !*got.Connected || got.StartConnected == nil || *got.StartConnected {
+				t.Errorf("%s %s: connected = %v, start connected = %v", a.want, b.name, got.Connected, got.StartConnected)
+			}
+
+			if got.NetworkName != b.want.NetworkName || got.PortgroupKey != b.want.PortgroupKey || got.SwitchUUID != b.want.SwitchUUID ||
+				got.OpaqueNetworkID != b.want.OpaqueNetworkID || (got.Network == nil) != (b.want.Network == nil) {
+				t.Errorf("%s %s: network = %+v, want %+v", a.want, b.name, got, b.want)
+			}
+		}
+	}
+}
+
+func TestNewDeviceInventoryOthers(t *testing.T) {
+	unit := int32(0)
+
+	scsi := &types.ParaVirtualSCSIController{}
+	scsi.Key, scsi.BusNumber, scsi.SharedBus, scsi.Device = 1000, 0, types.VirtualSCSISharingPhysicalSharing, []int32{2000}
+
+	ide := &types.VirtualIDEController{}
+	ide.Key, ide.BusNumber = 200, 1
+
+	cdroms := []struct {
+		backing types.BaseVirtualDeviceBackingInfo
+		typ     string
+	}{
+		{&types.VirtualCdromIsoBackingInfo{VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{FileName: "[ds1] iso/os.iso"}}, CDROMBackingISO},
+		{&types.VirtualCdromAtapiBackingInfo{VirtualDeviceDeviceBackingInfo: types.VirtualDeviceDeviceBackingInfo{DeviceName: "/dev/cdrom"}}, CDROMBackingHost},
+		{&types.VirtualCdromPassthroughBackingInfo{VirtualDeviceDeviceBackingInfo: types.VirtualDeviceDeviceBackingInfo{DeviceName: "/dev/cdrom"}}, CDROMBackingPassthrough},
+		{&types.VirtualCdromRemoteAtapiBackingInfo{VirtualDeviceRemoteDeviceBackingInfo: types.VirtualDeviceRemoteDeviceBackingInfo{DeviceName: "client"}}, CDROMBackingRemoteAtapi},
+		{&types.VirtualCdromRemotePassthroughBackingInfo{VirtualDeviceRemoteDeviceBackingInfo: types.VirtualDeviceRemoteDeviceBackingInfo{DeviceName: "client"}}, CDROMBackingRemotePassthrough},
+		{nil, CDROMBackingOther},
+	}
+
+	devices := object.VirtualDeviceList{scsi, ide}
+	for i, c := range cdroms {
+		cdrom := &types.VirtualCdrom{}
+		cdrom.Key, cdrom.ControllerKey, cdrom.UnitNumber, cdrom.Backing = int32(3000+i), 200, &unit, c.backing
+		devices = append(devices, cdrom)
+	}
+
+	usb := &types.VirtualUSB{Connected: true, Vendor: 0x1234, Product: 0x5678}
+	usb.Key, usb.Backing = 4100, &types.VirtualUSBRemoteClientBackingInfo{Hostname: "workstation"}
+	floppy := &types.VirtualFloppy{}
+	floppy.Key = 8000
+	devices = append(devices, usb, floppy)
+
+	inv := NewDeviceInventory(devices)
+
+	if len(inv.Controllers) != 2 || inv.Controllers[0].SharedBus != string(types.VirtualSCSISharingPhysicalSharing) ||
+		len(inv.Controllers[0].Devices) != 1 || inv.Controllers[1].SharedBus != "" || inv.Controllers[1].BusNumber != 1 {
+		t.Errorf("controllers = %+v", inv.Controllers)
+	}
+
+	if len(inv.CDROMs) != len(cdroms) {
+		t.Fatalf("%d cdroms, want %d", len(inv.CDROMs), len(cdroms))
+	}
+	for i, c := range cdroms {
+		got := inv.CDROMs[i]
+		if got.BackingType != c.typ {
+			t.Errorf("cdrom %d: backing = %s, want %s", i, got.BackingType, c.typ)
+		}
+		if c.typ == CDROMBackingISO && got.FileName != "[ds1] iso/os.iso" {
+			t.Errorf("cdrom %d: file = %q", i, got.FileName)
+		}
+		if c.typ != CDROMBackingISO && c.typ != CDROMBackingOther && got.DeviceName == "" {
+			t.Errorf("cdrom %d: no device name", i)
+		}
+	}
+
+	if len(inv.USB) != 1 || inv.USB[0].DeviceName != "workstation" || inv.USB[0].Connected == nil || !*inv.USB[0].Connected {
+		t.Errorf("usb = %+v", inv.USB)
+	}
+
+	if len(inv.Other) != 1 || inv.Other[0].Key != 8000 {
+		t.Errorf("other = %+v", inv.Other)
+	}
+}
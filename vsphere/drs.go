@@ -0,0 +1,412 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// VMGroup is a DRS group of virtual machines, by name or inventory path.
+type VMGroup struct {
+	Name string
+	VMs  []string
+}
+
+// HostGroup is a DRS group of hosts, by name or inventory path.
+type HostGroup struct {
+	Name  string
+	Hosts []string
+}
+
+// AffinityRule keeps VMs together on one host, or with AntiAffinity on different hosts.
+type AffinityRule struct {
+	Name         string
+	AntiAffinity bool
+	Disabled     bool
+	VMs          []string
+}
+
+// VMHostRule keeps the VMs of VMGroup on, or with AntiAffineHostGroup off, the hosts of a host group.
+// Set exactly one of AffineHostGroup and AntiAffineHostGroup. Mandatory makes it a "must" rule that
+// HA and DRS will not violate, otherwise it is a "should" rule.
+type VMHostRule struct {
+	Name                string
+	Disabled            bool
+	Mandatory           bool
+	VMGroup             string
+	AffineHostGroup     string
+	AntiAffineHostGroup string
+}
+
+// ClusterRules are the DRS groups and rules of a cluster.
+type ClusterRules struct {
+	VMGroups      []VMGroup
+	HostGroups    []HostGroup
+	AffinityRules []AffinityRule
+	VMHostRules   []VMHostRule
+}
+
+func findCluster(ctx context.Context, c *vim25.Client, cluster string) (*object.ClusterComputeResource, *types.ClusterConfigInfoEx, error) {
+	ccr, err := find.NewFinder(c).ClusterComputeResource(ctx, cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := ccr.Configuration(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ccr, config, nil
+}
+
+func reconfigureCluster(ctx context.Context, ccr *object.ClusterComputeResource, spec *types.ClusterConfigSpecEx) error {
+	task, err := ccr.Reconfigure(ctx, spec, true)
+	if err != nil {
+		return err
+	}
+
+	return task.Wait(ctx)
+}
+
+// names returns the sorted names of refs.
+func names(ctx context.Context, c *vim25.Client, refs []types.ManagedObjectReference) ([]string, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	var entities []mo.ManagedEntity
+
+	err := property.DefaultCollector(c).Retrieve(ctx, refs, []string{"name"}, &entities)
+	if err != nil {
+		return nil, err
+	}
+
+	s := make([]string, len(entities))
+	for i, e := range entities {
+		s[i] = e.Name
+	}
+	sort.Strings(s)
+
+	return s, nil
+}
+
+func findVMs(ctx context.Context, c *vim25.Client, vms []string) ([]types.ManagedObjectReference, error) {
+	finder := find.NewFinder(c)
+
+	refs := make([]types.ManagedObjectReference, len(vms))
+	for i, name := range vms {
+		vm, err := finder.VirtualMachine(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = vm.Reference()
+	}
+
+	return refs, nil
+}
+
+func findHosts(ctx context.Context, c *vim25.Client, hosts []string) ([]types.ManagedObjectReference, error) {
+	finder := find.NewFinder(c)
+
+	refs := make([]types.ManagedObjectReference, len(hosts))
+	for i, name := range hosts {
+		host, err := finder.HostSystem(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = host.Reference()
+	}
+
+	return refs, nil
+}
+
+// sameRefs reports whether a and b hold the same references, in any order.
+func sameRefs(a, b []types.ManagedObjectReference) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := map[types.ManagedObjectReference]int{}
+	for _, ref := range a {
+		set[ref]++
+	}
+	for _, ref := range b {
+		if set[ref] == 0 {
+			return false
+		}
+		set[ref]--
+	}
+
+	return true
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+func findRule(config *types.ClusterConfigInfoEx, name string) types.BaseClusterRuleInfo {
+	for _, rule := range config.Rule {
+		if rule.GetClusterRuleInfo().Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+func findGroup(config *types.ClusterConfigInfoEx, name string) types.BaseClusterGroupInfo {
+	for _, group := range config.Group {
+		if group.GetClusterGroupInfo().Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// GetClusterRules returns the VM and host groups and the affinity and VM-Host rules of cluster.
+func GetClusterRules(ctx context.Context, c *vim25.Client, cluster string) (*ClusterRules, error) {
+	_, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules ClusterRules
+
+	for _, group := range config.Group {
+		switch g := group.(type) {
+		case *types.ClusterVmGroup:
+			vms, err := names(ctx, c, g.Vm)
+			if err != nil {
+				return nil, err
+			}
+			rules.VMGroups = append(rules.VMGroups, VMGroup{Name: g.Name, VMs: vms})
+		case *types.ClusterHostGroup:
+			hosts, err := names(ctx, c, g.Host)
+			if err != nil {
+				return nil, err
+			}
+			rules.HostGroups = append(rules.HostGroups, HostGroup{Name: g.Name, Hosts: hosts})
+		}
+	}
+
+	for _, rule := range config.Rule {
+		switch r := rule.(type) {
+		case *types.ClusterAffinityRuleSpec:
+			vms, err := names(ctx, c, r.Vm)
+			if err != nil {
+				return nil, err
+			}
+			rules.AffinityRules = append(rules.AffinityRules, AffinityRule{Name: r.Name, Disabled: !isTrue(r.Enabled), VMs: vms})
+		case *types.ClusterAntiAffinityRuleSpec:
+			vms, err := names(ctx, c, r.Vm)
+			if err != nil {
+				return nil, err
+			}
+			rules.AffinityRules = append(rules.AffinityRules, AffinityRule{Name: r.Name, AntiAffinity: true, Disabled: !isTrue(r.Enabled), VMs: vms})
+		case *types.ClusterVmHostRuleInfo:
+			rules.VMHostRules = append(rules.VMHostRules, VMHostRule{
+				Name:                r.Name,
+				Disabled:            !isTrue(r.Enabled),
+				Mandatory:           isTrue(r.Mandatory),
+				VMGroup:             r.VmGroupName,
+				AffineHostGroup:     r.AffineHostGroupName,
+				AntiAffineHostGroup: r.AntiAffineHostGroupName,
+			})
+		}
+	}
+
+	return &rules, nil
+}
+
+// ensureGroup adds group to cluster, or replaces the existing group of the same name if its members differ.
+func ensureGroup(ctx context.Context, ccr *object.ClusterComputeResource, config *types.ClusterConfigInfoEx, group types.BaseClusterGroupInfo, same func(types.BaseClusterGroupInfo) bool) error {
+	name := group.GetClusterGroupInfo().Name
+
+	spec := &types.ClusterConfigSpecEx{}
+
+	switch existing := findGroup(config, name); {
+	case existing == nil:
+		spec.GroupSpec = []types.ClusterGroupSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd}, Info: group}}
+	case same(existing):
+		return nil
+	default:
+		spec.GroupSpec = []types.ClusterGroupSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit}, Info: group}}
+	}
+
+	return reconfigureCluster(ctx, ccr, spec)
+}
+
+// EnsureVMGroup creates the VM group on cluster, or updates its members. Nothing is changed if it already matches.
+func EnsureVMGroup(ctx context.Context, c *vim25.Client, cluster string, group VMGroup) error {
+	ccr, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return err
+	}
+
+	vms, err := findVMs(ctx, c, group.VMs)
+	if err != nil {
+		return err
+	}
+
+	return ensureGroup(ctx, ccr, config, &types.ClusterVmGroup{ClusterGroupInfo: types.ClusterGroupInfo{Name: group.Name}, Vm: vms}, func(existing types.BaseClusterGroupInfo) bool {
+		g, ok := existing.(*types.ClusterVmGroup)
+		if !ok {
+			return false
+		}
+		return sameRefs(g.Vm, vms)
+	})
+}
+
+// EnsureHostGroup creates the host group on cluster, or updates its members. Nothing is changed if it already matches.
+func EnsureHostGroup(ctx context.Context, c *vim25.Client, cluster string, group HostGroup) error {
+	ccr, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := findHosts(ctx, c, group.Hosts)
+	if err != nil {
+		return err
+	}
+
+	return ensureGroup(ctx, ccr, config, &types.ClusterHostGroup{ClusterGroupInfo: types.ClusterGroupInfo{Name: group.Name}, Host: hosts}, func(existing types.BaseClusterGroupInfo) bool {
+		g, ok := existing.(*types.ClusterHostGroup)
+		if !ok {
+			return false
+		}
+		return sameRefs(g.Host, hosts)
+	})
+}
+
+// ensureRule adds rule to cluster, or replaces the existing rule of the same name if it differs.
+// A rule that changes type, e.g. from affinity to anti-affinity, is removed and added again.
+func ensureRule(ctx context.Context, ccr *object.ClusterComputeResource, config *types.ClusterConfigInfoEx, rule types.BaseClusterRuleInfo, same func(types.BaseClusterRuleInfo) bool) error {
+	info := rule.GetClusterRuleInfo()
+
+	spec := &types.ClusterConfigSpecEx{}
+
+	switch existing := findRule(config, info.Name); {
+	case existing == nil:
+		spec.RulesSpec = []types.ClusterRuleSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd}, Info: rule}}
+	case same(existing):
+		return nil
+	case reflect.TypeOf(existing) != reflect.TypeOf(rule):
+		key := existing.GetClusterRuleInfo().Key
+		spec.RulesSpec = []types.ClusterRuleSpec{
+			{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationRemove, RemoveKey: key}},
+			{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd}, Info: rule},
+		}
+	default:
+		info.Key = existing.GetClusterRuleInfo().Key
+		spec.RulesSpec = []types.ClusterRuleSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit}, Info: rule}}
+	}
+
+	return reconfigureCluster(ctx, ccr, spec)
+}
+
+// EnsureAffinityRule creates the affinity or anti-affinity rule on cluster, or updates it. Nothing is changed
+// if it already matches.
+func EnsureAffinityRule(ctx context.Context, c *vim25.Client, cluster string, rule AffinityRule) error {
+	ccr, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return err
+	}
+
+	vms, err := findVMs(ctx, c, rule.VMs)
+	if err != nil {
+		return err
+	}
+
+	enabled := !rule.Disabled
+	info := types.ClusterRuleInfo{Name: rule.Name, Enabled: &enabled}
+
+	var spec types.BaseClusterRuleInfo
+	if rule.AntiAffinity {
+		spec = &types.ClusterAntiAffinityRuleSpec{ClusterRuleInfo: info, Vm: vms}
+	} else {
+		spec = &types.ClusterAffinityRuleSpec{ClusterRuleInfo: info, Vm: vms}
+	}
+
+	return ensureRule(ctx, ccr, config, spec, func(existing types.BaseClusterRuleInfo) bool {
+		switch r := existing.(type) {
+		case *types.ClusterAffinityRuleSpec:
+			return !rule.AntiAffinity && isTrue(r.Enabled) == enabled && sameRefs(r.Vm, vms)
+		case *types.ClusterAntiAffinityRuleSpec:
+			return rule.AntiAffinity && isTrue(r.Enabled) == enabled && sameRefs(r.Vm, vms)
+		}
+		return false
+	})
+}
+
+// EnsureVMHostRule creates the VM-Host rule on cluster, or updates it. The VM and host groups must exist,
+// see EnsureVMGroup and EnsureHostGroup. Nothing is changed if it already matches.
+func EnsureVMHostRule(ctx context.Context, c *vim25.Client, cluster string, rule VMHostRule) error {
+	if (rule.AffineHostGroup == "") == (rule.AntiAffineHostGroup == "") {
+		return fmt.Errorf("rule %s: exactly one of the affine and anti-affine host group is required", rule.Name)
+	}
+
+	ccr, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return err
+	}
+
+	enabled, mandatory := !rule.Disabled, rule.Mandatory
+
+	spec := &types.ClusterVmHostRuleInfo{
+		ClusterRuleInfo:         types.ClusterRuleInfo{Name: rule.Name, Enabled: &enabled, Mandatory: &mandatory},
+		VmGroupName:             rule.VMGroup,
+		AffineHostGroupName:     rule.AffineHostGroup,
+		AntiAffineHostGroupName: rule.AntiAffineHostGroup,
+	}
+
+	return ensureRule(ctx, ccr, config, spec, func(existing types.BaseClusterRuleInfo) bool {
+		r, ok := existing.(*types.ClusterVmHostRuleInfo)
+		if !ok {
+			return false
+		}
+		return isTrue(r.Enabled) == enabled && isTrue(r.Mandatory) == mandatory && r.VmGroupName == spec.VmGroupName &&
+			r.AffineHostGroupName == spec.AffineHostGroupName && r.AntiAffineHostGroupName == spec.AntiAffineHostGroupName
+	})
+}
+
+// DeleteClusterRule removes the rule called name from cluster. It is not an error if there is no such rule.
+func DeleteClusterRule(ctx context.Context, c *vim25.Client, cluster, name string) error {
+	ccr, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return err
+	}
+
+	rule := findRule(config, name)
+	if rule == nil {
+		return nil
+	}
+
+	return reconfigureCluster(ctx, ccr, &types.ClusterConfigSpecEx{
+		RulesSpec: []types.ClusterRuleSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationRemove, RemoveKey: rule.GetClusterRuleInfo().Key}}},
+	})
+}
+
+// DeleteClusterGroup removes the VM or host group called name from cluster. It is not an error if there is
+// no such group; VM-Host rules using the group have to be deleted first.
+func DeleteClusterGroup(ctx context.Context, c *vim25.Client, cluster, name string) error {
+	ccr, config, err := findCluster(ctx, c, cluster)
+	if err != nil {
+		return err
+	}
+
+	if findGroup(config, name) == nil {
+		return nil
+	}
+
+	return reconfigureCluster(ctx, ccr, &types.ClusterConfigSpecEx{
+		GroupSpec: []types.ClusterGroupSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationRemove, RemoveKey: name}}},
+	})
+}
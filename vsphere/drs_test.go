@@ -0,0 +1,123 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// reconfigureRecorder records the group and rule operations of every cluster reconfigure, e.g. "rule edit".
+type reconfigureRecorder struct {
+	roundTripper soap.RoundTripper
+	ops          []string
+}
+
+func (r *reconfigureRecorder) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	if body, ok := req.(*methods.ReconfigureComputeResource_TaskBody); ok {
+		spec := body.Req.Spec.(*types.ClusterConfigSpecEx)
+		for _, g := range spec.GroupSpec {
+			r.ops = append(r.ops, "group "+string(g.Operation))
+		}
+		for _, rule := range spec.RulesSpec {
+			r.ops = append(r.ops, "rule "+string(rule.Operation))
+		}
+	}
+
+	return r.roundTripper.RoundTrip(ctx, req, res)
+}
+
+func TestEnsureClusterRules(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		rec := &reconfigureRecorder{roundTripper: c.RoundTripper}
+		c.RoundTripper = rec
+
+		const cluster = "DC0_C0"
+		vm0, vm1 := "DC0_C0_RP0_VM0", "DC0_C0_RP0_VM1"
+
+		steps := []struct {
+			name string
+			run  func() error
+			ops  []string
+		}{
+			{"add vm group", func() error {
+				return EnsureVMGroup(ctx, c, cluster, VMGroup{Name: "vms", VMs: []string{vm0}})
+			}, []string{"group add"}},
+			{"vm group unchanged", func() error {
+				return EnsureVMGroup(ctx, c, cluster, VMGroup{Name: "vms", VMs: []string{vm0}})
+			}, nil},
+			{"vm group members", func() error {
+				return EnsureVMGroup(ctx, c, cluster, VMGroup{Name: "vms", VMs: []string{vm1, vm0}})
+			}, []string{"group edit"}},
+			{"vm group members in another order", func() error {
+				return EnsureVMGroup(ctx, c, cluster, VMGroup{Name: "vms", VMs: []string{vm0, vm1}})
+			}, nil},
+			{"add host group", func() error {
+				return EnsureHostGroup(ctx, c, cluster, HostGroup{Name: "hosts", Hosts: []string{"DC0_C0_H0"}})
+			}, []string{"group add"}},
+
+			{"add affinity rule", func() error {
+				return EnsureAffinityRule(ctx, c, cluster, AffinityRule{Name: "together", VMs: []string{vm0, vm1}})
+			}, []string{"rule add"}},
+			{"affinity rule unchanged", func() error {
+				return EnsureAffinityRule(ctx, c, cluster, AffinityRule{Name: "together", VMs: []string{vm0, vm1}})
+			}, nil},
+			{"affinity rule disabled", func() error {
+				return EnsureAffinityRule(ctx, c, cluster, AffinityRule{Name: "together", Disabled: true, VMs: []string{vm0, vm1}})
+			}, []string{"rule edit"}},
+			{"affinity to anti-affinity", func() error {
+				return EnsureAffinityRule(ctx, c, cluster, AffinityRule{Name: "together", AntiAffinity: true, VMs: []string{vm0, vm1}})
+			}, []string{"rule remove", "rule add"}},
+			{"anti-affinity rule unchanged", func() error {
+				return EnsureAffinityRule(ctx, c, cluster, AffinityRule{Name: "together", AntiAffinity: true, VMs: []string{vm0, vm1}})
+			}, nil},
+
+			{"add vm-host rule", func() error {
+				return EnsureVMHostRule(ctx, c, cluster, VMHostRule{Name: "placement", VMGroup: "vms", AffineHostGroup: "hosts"})
+			}, []string{"rule add"}},
+			{"vm-host rule unchanged", func() error {
+				return EnsureVMHostRule(ctx, c, cluster, VMHostRule{Name: "placement", VMGroup: "vms", AffineHostGroup: "hosts"})
+			}, nil},
+			{"vm-host rule mandatory", func() error {
+				return EnsureVMHostRule(ctx, c, cluster, VMHostRule{Name: "placement", Mandatory: true, VMGroup: "vms", AffineHostGroup: "hosts"})
+			}, []string{"rule edit"}},
+
+			{"delete missing rule", func() error { return DeleteClusterRule(ctx, c, cluster, "missing") }, nil},
+			{"delete missing group", func() error { return DeleteClusterGroup(ctx, c, cluster, "missing") }, nil},
+			{"delete rule", func() error { return DeleteClusterRule(ctx, c, cluster, "placement") }, []string{"rule remove"}},
+			{"delete rule again", func() error { return DeleteClusterRule(ctx, c, cluster, "placement") }, nil},
+			{"delete group", func() error { return DeleteClusterGroup(ctx, c, cluster, "hosts") }, []string{"group remove"}},
+			{"delete group again", func() error { return DeleteClusterGroup(ctx, c, cluster, "hosts") }, nil},
+		}
+
+		for _, step := range steps {
+			rec.ops = nil
+
+			if err := step.run(); err != nil {
+				t.Fatalf("%s: %s", step.name, err)
+			}
+
+			if !reflect.DeepEqual(rec.ops, step.ops) {
+				t.Errorf("%s: reconfigured with %v, want %v", step.name, rec.ops, step.ops)
+			}
+		}
+
+		rules, err := GetClusterRules(ctx, c, cluster)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := &ClusterRules{
+			VMGroups:      []VMGroup{{Name: "vms", VMs: []string{vm0, vm1}}},
+			AffinityRules: []AffinityRule{{Name: "together", AntiAffinity: true, VMs: []string{vm0, vm1}}},
+		}
+		if !reflect.DeepEqual(rules, want) {
+			t.Errorf("GetClusterRules = %+v, want %+v", rules, want)
+		}
+	})
+}
@@ -0,0 +1,378 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// Table formats, next to FormatJSON and FormatYAML.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Table is inventory data laid out for a spreadsheet. Cells are strings, integers or floats, so XLSX output
+// keeps numbers as numbers.
+type Table struct {
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Write writes t to w in format, FormatCSV or FormatXLSX.
+func (t *Table) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatCSV:
+		return t.writeCSV(w)
+	case FormatXLSX:
+		return t.writeXLSX(w)
+	default:
+		return fmt.Errorf("unknown table format %q", format)
+	}
+}
+
+func (t *Table) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Header))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			record[i] = formatCell(v)
+			if _, ok := v.(string); ok {
+				record[i] = escapeFormula(record[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// escapeFormula prefixes text a spreadsheet would evaluate as a formula with a quote, so values taken
+// from the inventory, such as annotations, open as text. Numbers are not strings and are left alone.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+func formatCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// gib converts bytes to GiB rounded to two decimals.
+func gib(bytes int64) float64 {
+	return math.Round(float64(bytes)/(1<<30)*100) / 100
+}
+
+// VMColumn is a column of a VM table, with the properties Value needs retrieved.
+type VMColumn struct {
+	Header     string
+	Properties []string
+	Value      func(vm *mo.VirtualMachine) interface{}
+}
+
+// HostColumn is a column of a host table, with the properties Value needs retrieved.
+type HostColumn struct {
+	Header     string
+	Properties []string
+	Value      func(host *mo.HostSystem) interface{}
+}
+
+// storageUsage sums the per datastore usage of vm into committed, uncommitted and unshared bytes.
+func storageUsage(vm *mo.VirtualMachine) (committed, uncommitted, unshared int64) {
+	if vm.Storage == nil {
+		return
+	}
+
+	for _, u := range vm.Storage.PerDatastoreUsage {
+		committed += u.Committed
+		uncommitted += u.Uncommitted
+		unshared += u.Unshared
+	}
+
+	return
+}
+
+// VMColumns are the columns ExportVirtualMachines knows by header. Storage is reported from
+// storage.perDatastoreUsage: used is what is committed on the datastores, provisioned is used plus what
+// thin disks may still grow by.
+var VMColumns = []VMColumn{
+	{"name", []string{"name"}, func(vm *mo.VirtualMachine) interface{} { return vm.Name }},
+	{"power_state", []string{"runtime.powerState"}, func(vm *mo.VirtualMachine) interface{} { return string(vm.Runtime.PowerState) }},
+	{"template", []string{"summary.config.template"}, func(vm *mo.VirtualMachine) interface{} { return vm.Summary.Config.Template }},
+	{"uuid", []string{"summary.config.uuid"}, func(vm *mo.VirtualMachine) interface{} { return vm.Summary.Config.Uuid }},
+	{"guest_os", []string{"summary.config.guestFullName"}, func(vm *mo.VirtualMachine) interface{} { return vm.Summary.Config.GuestFullName }},
+	{"num_cpu", []string{"summary.config.numCpu"}, func(vm *mo.VirtualMachine) interface{} { return int64(vm.Summary.Config.NumCpu) }},
+	{"memory_mb", []string{"summary.config.memorySizeMB"}, func(vm *mo.VirtualMachine) interface{} { return int64(vm.Summary.Config.MemorySizeMB) }},
+	{"hostname", []string{"guest.hostName"}, func(vm *mo.VirtualMachine) interface{} {
+		if vm.Guest == nil {
+			return nil
+		}
+		return vm.Guest.HostName
+	}},
+	{"ip_address", []string{"guest.ipAddress"}, func(vm *mo.VirtualMachine) interface{} {
+		if vm.Guest == nil {
+			return nil
+		}
+		return vm.Guest.IpAddress
+	}},
+	{"tools_status", []string{"guest.toolsVersionStatus2"}, func(vm *mo.VirtualMachine) interface{} {
+		if vm.Guest == nil {
+			return nil
+		}
+		return vm.Guest.ToolsVersionStatus2
+	}},
+	{"provisioned_gb", []string{"storage.perDatastoreUsage"}, func(vm *mo.VirtualMachine) interface{} {
+		committed, uncommitted, _ := storageUsage(vm)
+		return gib(committed + uncommitted)
+	}},
+	{"used_gb", []string{"storage.perDatastoreUsage"}, func(vm *mo.VirtualMachine) interface{} {
+		committed, _, _ := storageUsage(vm)
+		return gib(committed)
+	}},
+	{"unshared_gb", []string{"storage.perDatastoreUsage"}, func(vm *mo.VirtualMachine) interface{} {
+		_, _, unshared := storageUsage(vm)
+		return gib(unshared)
+	}},
+	{"annotation", []string{"summary.config.annotation"}, func(vm *mo.VirtualMachine) interface{} { return vm.Summary.Config.Annotation }},
+}
+
+// HostColumns are the columns ExportHosts knows by header.
+var HostColumns = []HostColumn{
+	{"name", []string{"name"}, func(h *mo.HostSystem) interface{} { return h.Name }},
+	{"connection_state", []string{"runtime.connectionState"}, func(h *mo.HostSystem) interface{} { return string(h.Runtime.ConnectionState) }},
+	{"power_state", []string{"runtime.powerState"}, func(h *mo.HostSystem) interface{} { return string(h.Runtime.PowerState) }},
+	{"maintenance", []string{"runtime.inMaintenanceMode"}, func(h *mo.HostSystem) interface{} { return h.Runtime.InMaintenanceMode }},
+	{"version", []string{"summary.config.product"}, func(h *mo.HostSystem) interface{} {
+		if h.Summary.Config.Product == nil {
+			return nil
+		}
+		return h.Summary.Config.Product.FullName
+	}},
+	{"vendor", []string{"summary.hardware"}, func(h *mo.HostSystem) interface{} {
+		if h.Summary.Hardware == nil {
+			return nil
+		}
+		return h.Summary.Hardware.Vendor
+	}},
+	{"model", []string{"summary.hardware"}, func(h *mo.HostSystem) interface{} {
+		if h.Summary.Hardware == nil {
+			return nil
+		}
+		return h.Summary.Hardware.Model
+	}},
+	{"cpu_model", []string{"summary.hardware"}, func(h *mo.HostSystem) interface{} {
+		if h.Summary.Hardware == nil {
+			return nil
+		}
+		return h.Summary.Hardware.CpuModel
+	}},
+	{"cpu_cores", []string{"summary.hardware"}, func(h *mo.HostSystem) interface{} {
+		if h.Summary.Hardware == nil {
+			return nil
+		}
+		return int64(h.Summary.Hardware.NumCpuCores)
+	}},
+	{"memory_gb", []string{"summary.hardware"}, func(h *mo.HostSystem) interface{} {
+		if h.Summary.Hardware == nil {
+			return nil
+		}
+		return gib(h.Summary.Hardware.MemorySize)
+	}},
+	{"cpu_usage_mhz", []string{"summary.quickStats"}, func(h *mo.HostSystem) interface{} { return int64(h.Summary.QuickStats.OverallCpuUsage) }},
+	{"memory_usage_mb", []string{"summary.quickStats"}, func(h *mo.HostSystem) interface{} { return int64(h.Summary.QuickStats.OverallMemoryUsage) }},
+	{"uptime_seconds", []string{"summary.quickStats"}, func(h *mo.HostSystem) interface{} { return int64(h.Summary.QuickStats.Uptime) }},
+}
+
+// uniqueProperties returns the properties of all columns, each once.
+func uniqueProperties(lists ...[]string) []string {
+	seen := map[string]bool{}
+	var ps []string
+
+	for _, list := range lists {
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				ps = append(ps, p)
+			}
+		}
+	}
+
+	return ps
+}
+
+// SelectVMColumns returns the VMColumns with the given headers in that order, all of them if none are given.
+func SelectVMColumns(headers ...string) ([]VMColumn, error) {
+	if len(headers) == 0 {
+		return VMColumns, nil
+	}
+
+	cols := make([]VMColumn, len(headers))
+	for i, h := range headers {
+		found := false
+		for _, col := range VMColumns {
+			if col.Header == h {
+				cols[i], found = col, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown vm column %q", h)
+		}
+	}
+
+	return cols, nil
+}
+
+// SelectHostColumns returns the HostColumns with the given headers in that order, all of them if none are given.
+func SelectHostColumns(headers ...string) ([]HostColumn, error) {
+	if len(headers) == 0 {
+		return HostColumns, nil
+	}
+
+	cols := make([]HostColumn, len(headers))
+	for i, h := range headers {
+		found := false
+		for _, col := range HostColumns {
+			if col.Header == h {
+				cols[i], found = col, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown host column %q", h)
+		}
+	}
+
+	return cols, nil
+}
+
+// sortedIndexes returns the indexes 0 to n-1 stably sorted by less, which compares the elements at
+// two indexes. Sorting indexes leaves the order of the caller's slice alone.
+func sortedIndexes(n int, less func(i, j int) bool) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return less(order[i], order[j]) })
+
+	return order
+}
+
+// NewVMTable lays out vms as a table with the given columns, sorted by name.
+func NewVMTable(vms []mo.VirtualMachine, cols []VMColumn) *Table {
+	order := sortedIndexes(len(vms), func(i, j int) bool { return vms[i].Name < vms[j].Name })
+
+	t := Table{Header: make([]string, len(cols))}
+	for i, col := range cols {
+		t.Header[i] = col.Header
+	}
+
+	for _, i := range order {
+		row := make([]interface{}, len(cols))
+		for j, col := range cols {
+			row[j] = col.Value(&vms[i])
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return &t
+}
+
+// NewHostTable lays out hosts as a table with the given columns, sorted by name.
+func NewHostTable(hosts []mo.HostSystem, cols []HostColumn) *Table {
+	order := sortedIndexes(len(hosts), func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+	t := Table{Header: make([]string, len(cols))}
+	for i, col := range cols {
+		t.Header[i] = col.Header
+	}
+
+	for _, i := range order {
+		row := make([]interface{}, len(cols))
+		for j, col := range cols {
+			row[j] = col.Value(&hosts[i])
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return &t
+}
+
+// ExportVirtualMachines writes the VMs whose name matches namepattern to w in format, FormatCSV or
+// FormatXLSX, with the VMColumns named by columns, all of them if none are given. Only the properties the
+// columns need are retrieved. opts scope the query as for GetVirtualMachines.
+func ExportVirtualMachines(ctx context.Context, c *vim25.Client, w io.Writer, format, namepattern string, columns []string, opts ...InventoryOption) error {
+	cols, err := SelectVMColumns(columns...)
+	if err != nil {
+		return err
+	}
+
+	var ps [][]string
+	for _, col := range cols {
+		ps = append(ps, col.Properties)
+	}
+
+	opts = appendOpts(opts, WithProperties(uniqueProperties(append(ps, []string{"name"})...)...))
+
+	vms, err := GetVirtualMachines(ctx, c, namepattern, opts...)
+	if err != nil {
+		return err
+	}
+
+	return NewVMTable(vms, cols).Write(w, format)
+}
+
+// ExportHosts is ExportVirtualMachines for hosts, with the HostColumns named by columns.
+func ExportHosts(ctx context.Context, c *vim25.Client, w io.Writer, format, namepattern string, columns []string, opts ...InventoryOption) error {
+	cols, err := SelectHostColumns(columns...)
+	if err != nil {
+		return err
+	}
+
+	var ps [][]string
+	for _, col := range cols {
+		ps = append(ps, col.Properties)
+	}
+
+	opts = appendOpts(opts, WithProperties(uniqueProperties(append(ps, []string{"name"})...)...))
+
+	hosts, err := GetHosts(ctx, c, namepattern, opts...)
+	if err != nil {
+		return err
+	}
+
+	return NewHostTable(hosts, cols).Write(w, format)
+}
@@ -0,0 +1,183 @@
+package vsphere
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+func testTable() *Table {
+	return &Table{
+		Header: []string{"name", "count", "size", "on", "note"},
+		Rows: [][]interface{}{
+			{"vm1", int64(-2), 1.5, true, "=HYPERLINK(\"x\")"},
+			{"-vm2", int64(3), -0.25, false, nil},
+			{"a<b&c", int64(0), 0.0, true, "@sum"},
+		},
+	}
+}
+
+func TestTableWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testTable().Write(&buf, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"name", "count", "size", "on", "note"},
+		{"vm1", "-2", "1.5", "true", "'=HYPERLINK(\"x\")"},
+		{"'-vm2", "3", "-0.25", "false", ""},
+		{"a<b&c", "0", "0", "true", "'@sum"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("csv = %q\nwant %q", records, want)
+	}
+}
+
+func TestTableWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testTable().Write(&buf, FormatXLSX); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string][]byte{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name], err = ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v interface{}
+		if err = xml.Unmarshal(parts[f.Name], &v); err != nil {
+			t.Errorf("%s: %s", f.Name, err)
+		}
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook has no %s", name)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err = xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	type cell struct{ typ, value string }
+	var got [][]cell
+	for _, row := range sheet.Rows {
+		var cells []cell
+		for _, c := range row.Cells {
+			v := c.Value
+			if c.Type == "inlineStr" {
+				v = c.Inline
+			}
+			cells = append(cells, cell{c.Type, v})
+		}
+		got = append(got, cells)
+	}
+
+	s := func(v string) cell { return cell{"inlineStr", v} }
+	want := [][]cell{
+		{s("name"), s("count"), s("size"), s("on"), s("note")},
+		{s("vm1"), {"", "-2"}, {"", "1.5"}, {"b", "1"}, s("=HYPERLINK(\"x\")")},
+		{s("-vm2"), {"", "3"}, {"", "-0.25"}, {"b", "0"}, {"", ""}},
+		{s("a<b&c"), {"", "0"}, {"", "0"}, {"b", "1"}, s("@sum")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sheet = %v\nwant %v", got, want)
+	}
+}
+
+func TestTableWriteUnknownFormat(t *testing.T) {
+	if err := testTable().Write(ioutil.Discard, "ods"); err == nil {
+		t.Error("Write accepted an unknown format")
+	}
+}
+
+func TestNewVMTableKeepsOrder(t *testing.T) {
+	vms := []mo.VirtualMachine{{}, {}, {}}
+	vms[0].Name, vms[1].Name, vms[2].Name = "c", "a", "b"
+
+	cols, err := SelectVMColumns("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := NewVMTable(vms, cols)
+
+	if got := []string{vms[0].Name, vms[1].Name, vms[2].Name}; !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+		t.Errorf("NewVMTable reordered the caller's slice to %v", got)
+	}
+
+	want := [][]interface{}{{"a"}, {"b"}, {"c"}}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("rows = %v, want %v", table.Rows, want)
+	}
+}
+
+func TestExportKeepsOptions(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		// spare capacity that appending to opts would write into
+		opts := make([]InventoryOption, 1, 4)
+		opts[0] = WithProperties("name")
+
+		var buf bytes.Buffer
+		if err := ExportVirtualMachines(ctx, c, &buf, FormatCSV, "*", []string{"name"}, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		if opts[:2][1] != nil {
+			t.Error("ExportVirtualMachines wrote into the caller's options")
+		}
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) < 2 {
+			t.Errorf("exported %d rows, want the simulator VMs", len(records)-1)
+		}
+
+		if err = ExportHosts(ctx, c, ioutil.Discard, FormatCSV, "*", []string{"name"}, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		if opts[:2][1] != nil {
+			t.Error("ExportHosts wrote into the caller's options")
+		}
+	})
+}
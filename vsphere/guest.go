@@ -3,32 +3,44 @@ package vsphere
 import (
 	"context"
 	"fmt"
-	"github.com/roshankarande/go-vsphere/vsphere/guest/toolbox"
+	"github.com/roshankarande/utils/telemetry"
+	"github.com/roshankarande/utils/vsphere/guest/toolbox"
 	"github.com/vmware/govmomi/guest"
 	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 	"io"
 )
 
-func InvokeCommands(ctx context.Context,auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager, data chan string, commands []string) error {
+func newToolboxClient(ctx context.Context, auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager) (*toolbox.Client, error) {
 
 	pmgr, err := opsmgr.ProcessManager(ctx)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	fmgr, err := opsmgr.FileManager(ctx)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tboxClient := &toolbox.Client{
+	return &toolbox.Client{
 		ProcessManager: pmgr,
 		FileManager:    fmgr,
 		Authentication: auth,
 		GuestFamily:    types.VirtualMachineGuestOsFamilyWindowsGuest,
+	}, nil
+}
+
+func InvokeCommands(ctx context.Context,auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager, data chan string, commands []string) (err error) {
+	ctx, op := telemetry.Start(ctx, "vsphere.InvokeCommands")
+	defer func() { op.End(err) }()
+
+	tboxClient, err := newToolboxClient(ctx, auth, opsmgr)
+
+	if err != nil {
+		return err
 	}
 
 	err = tboxClient.RunCommands(ctx,data,commands)
@@ -40,28 +52,36 @@ func InvokeCommands(ctx context.Context,auth types.BaseGuestAuthentication, opsm
 	return nil
 }
 
-func InvokeScript(ctx context.Context,auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager,data chan string, script string) error {
+func InvokeScript(ctx context.Context,auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager,data chan string, script string) (err error) {
+	ctx, op := telemetry.Start(ctx, "vsphere.InvokeScript")
+	defer func() { op.End(err) }()
 
-	pmgr, err := opsmgr.ProcessManager(ctx)
+	tboxClient, err := newToolboxClient(ctx, auth, opsmgr)
 
 	if err != nil {
 		return err
 	}
 
-	fmgr, err := opsmgr.FileManager(ctx)
+	err = tboxClient.RunScript(ctx,data,script)
 
 	if err != nil {
 		return err
 	}
 
-	tboxClient := &toolbox.Client{
-		ProcessManager: pmgr,
-		FileManager:    fmgr,
-		Authentication: auth,
-		GuestFamily:    types.VirtualMachineGuestOsFamilyWindowsGuest,
+	return nil
+}
+
+func InvokeElevated(ctx context.Context, auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager, data chan string, script string, opts toolbox.ElevatedOptions) (err error) {
+	ctx, op := telemetry.Start(ctx, "vsphere.InvokeElevated")
+	defer func() { op.End(err) }()
+
+	tboxClient, err := newToolboxClient(ctx, auth, opsmgr)
+
+	if err != nil {
+		return err
 	}
 
-	err = tboxClient.RunScript(ctx,data,script)
+	err = tboxClient.RunElevated(ctx, data, script, opts)
 
 	if err != nil {
 		return err
@@ -87,27 +107,16 @@ func TestCredentials(ctx context.Context, baseGuestAuth types.BaseGuestAuthentic
 	return nil
 }
 
-func Upload(ctx context.Context, auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager, f io.Reader, suffix, dst string, isDir bool) error {
-
-	pmgr, err := opsmgr.ProcessManager(ctx)
-
-	if err != nil {
-		return err
-	}
+func Upload(ctx context.Context, auth types.BaseGuestAuthentication, opsmgr *guest.OperationsManager, f io.Reader, suffix, dst string, isDir bool) (err error) {
+	ctx, op := telemetry.Start(ctx, "vsphere.Upload")
+	defer func() { op.End(err) }()
 
-	fmgr, err := opsmgr.FileManager(ctx)
+	c, err := newToolboxClient(ctx, auth, opsmgr)
 
 	if err != nil {
 		return err
 	}
 
-	c := &toolbox.Client{
-		ProcessManager: pmgr,
-		FileManager:    fmgr,
-		Authentication: auth,
-		GuestFamily:    types.VirtualMachineGuestOsFamilyWindowsGuest,
-	}
-
 	vcFile, err := c.FileManager.CreateTemporaryFile(ctx, c.Authentication, "", suffix, "")
 
 	if err != nil {
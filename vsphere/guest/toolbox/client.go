@@ -5,16 +5,19 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/url"
 	"os"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/roshankarande/utils/logging"
+	"github.com/roshankarande/utils/telemetry"
 	"github.com/vmware/govmomi/guest"
 	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel/label"
 )
 
 // Client attempts to expose guest.OperationsManager as idiomatic Go interfaces
@@ -28,21 +31,57 @@ type Client struct {
 func (c *Client) rm(ctx context.Context, path string) {
 	err := c.FileManager.DeleteFile(ctx, c.Authentication, path)
 	if err != nil {
-		log.Printf("rm %q: %s", path, err)
+		logging.Warn(ctx, "rm failed", logging.Fields{"path": path, "error": err})
 	}
 }
 
+var commandSeq uint64
+
+// startProgram starts spec under a new command id and returns a context carrying the command id and pid for logging.
+func (c *Client) startProgram(ctx context.Context, spec *types.GuestProgramSpec) (context.Context, int64, error) {
+	ctx = logging.WithFields(ctx, logging.Fields{"command_id": atomic.AddUint64(&commandSeq, 1)})
+
+	logging.Debug(ctx, "starting guest program", logging.Fields{"program": spec.ProgramPath})
+
+	pid, err := c.ProcessManager.StartProgram(ctx, c.Authentication, spec)
+	if err != nil {
+		logging.Error(ctx, "starting guest program failed", logging.Fields{"error": err})
+		return ctx, 0, err
+	}
+
+	ctx = logging.WithFields(ctx, logging.Fields{"pid": pid})
+	logging.Debug(ctx, "guest program started", nil)
+
+	return ctx, pid, nil
+}
+
+// poll lists the guest program pid, once for every round of the Run* wait loops.
+func (c *Client) poll(ctx context.Context, pid int64) ([]types.GuestProcessInfo, error) {
+	ctx, op := telemetry.Start(ctx, "toolbox.poll")
+	op.SetAttributes(label.Int64("pid", pid))
+
+	procs, err := c.ProcessManager.ListProcesses(ctx, c.Authentication, []int64{pid})
+	op.End(err)
+
+	return procs, err
+}
+
 func (c *Client) mktemp(ctx context.Context) (string, error) {
 	return c.FileManager.CreateTemporaryFile(ctx, c.Authentication, "govmomi-", "", "")
 }
 
-type exitError struct {
-	error
-	exitCode int
+// ExitError is returned by the Run methods when the guest program exits with a non-zero code.
+type ExitError struct {
+	Err  error
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
 }
 
-func (e *exitError) ExitCode() int {
-	return e.exitCode
+func (e *ExitError) ExitCode() int {
+	return e.Code
 }
 
 // Run implements exec.Cmd.Run over vmx guest RPC against standard vmware-tools or toolbox.
@@ -102,7 +141,7 @@ func (c *Client) Run(ctx context.Context, cmd *exec.Cmd, data chan string) error
 		WorkingDirectory: cmd.Dir,
 	}
 
-	pid, err := c.ProcessManager.StartProgram(ctx, c.Authentication, &spec)
+	ctx, pid, err := c.startProgram(ctx, &spec)
 	if err != nil {
 		return err
 	}
@@ -112,7 +151,7 @@ func (c *Client) Run(ctx context.Context, cmd *exec.Cmd, data chan string) error
 
 	for {
 
-		procs, err := c.ProcessManager.ListProcesses(ctx, c.Authentication, []int64{pid})
+		procs, err := c.poll(ctx, pid)
 		if err != nil {
 			return err
 		}
@@ -165,8 +204,10 @@ func (c *Client) Run(ctx context.Context, cmd *exec.Cmd, data chan string) error
 		}
 	}
 
+	logging.Debug(ctx, "guest program exited", logging.Fields{"exit_code": rc})
+
 	if rc != 0 {
-		return &exitError{fmt.Errorf("%s: exit %d", cmd.Path, rc), rc}
+		return &ExitError{fmt.Errorf("%s: exit %d", cmd.Path, rc), rc}
 	}
 
 	return nil
@@ -224,7 +265,7 @@ func (c *Client) RunCommands(ctx context.Context, data chan string, commands []s
 		//	arg := "'" + strings.Join(append([]string{cmd.Path}, args...), " ") + "'"
 		//	args = []string{"-c", arg}
 		//}
-		fmt.Errorf("not a windows machine")
+		return fmt.Errorf("not a windows machine")
 	}
 
 	spec := types.GuestProgramSpec{
@@ -234,9 +275,7 @@ func (c *Client) RunCommands(ctx context.Context, data chan string, commands []s
 		EnvVariables:     nil,
 	}
 
-	fmt.Println(spec.ProgramPath, spec.Arguments)
-
-	pid, err := c.ProcessManager.StartProgram(ctx, c.Authentication, &spec)
+	ctx, pid, err := c.startProgram(ctx, &spec)
 	if err != nil {
 		return err
 	}
@@ -246,7 +285,7 @@ func (c *Client) RunCommands(ctx context.Context, data chan string, commands []s
 
 	for {
 
-		procs, err := c.ProcessManager.ListProcesses(ctx, c.Authentication, []int64{pid})
+		procs, err := c.poll(ctx, pid)
 		if err != nil {
 			return err
 		}
@@ -301,8 +340,10 @@ func (c *Client) RunCommands(ctx context.Context, data chan string, commands []s
 		}
 	}
 
+	logging.Debug(ctx, "guest program exited", logging.Fields{"exit_code": rc})
+
 	if rc != 0 {
-		return &exitError{fmt.Errorf("%s: exit %d", path, rc), rc}
+		return &ExitError{fmt.Errorf("%s: exit %d", path, rc), rc}
 	}
 
 	return nil
@@ -345,7 +386,7 @@ func (c *Client) RunCommand(ctx context.Context, data chan string, command strin
 		path = "C:\\WINDOWS\\system32\\WindowsPowerShell\\v1.0\\powershell.exe"
 		args = []string{"-Command", fmt.Sprintf(`"& { %s }"`, command), "| Out-File", output[0].path, "-encoding ASCII"}
 	default:
-		fmt.Errorf("not a windows machine")
+		return fmt.Errorf("not a windows machine")
 	}
 
 	spec := types.GuestProgramSpec{
@@ -355,7 +396,7 @@ func (c *Client) RunCommand(ctx context.Context, data chan string, command strin
 		EnvVariables:     nil,
 	}
 
-	pid, err := c.ProcessManager.StartProgram(ctx, c.Authentication, &spec)
+	ctx, pid, err := c.startProgram(ctx, &spec)
 	if err != nil {
 		return err
 	}
@@ -365,7 +406,7 @@ func (c *Client) RunCommand(ctx context.Context, data chan string, command strin
 
 	for {
 
-		procs, err := c.ProcessManager.ListProcesses(ctx, c.Authentication, []int64{pid})
+		procs, err := c.poll(ctx, pid)
 		if err != nil {
 			return err
 		}
@@ -422,8 +463,10 @@ func (c *Client) RunCommand(ctx context.Context, data chan string, command strin
 		}
 	}
 
+	logging.Debug(ctx, "guest program exited", logging.Fields{"exit_code": rc})
+
 	if rc != 0 {
-		return &exitError{fmt.Errorf("%s: exit %d", path, rc), rc}
+		return &ExitError{fmt.Errorf("%s: exit %d", path, rc), rc}
 	}
 
 	return nil
@@ -464,7 +507,7 @@ func (c *Client) RunSimpleCommands(ctx context.Context, commands []string) error
 		path = "C:\\WINDOWS\\system32\\WindowsPowerShell\\v1.0\\powershell.exe"
 		args = []string{"-Command", fmt.Sprintf(`"& { %s }"`, strings.Join(commands, ";")), "| Out-File", output[0].path, "-encoding ASCII"}
 	default:
-		fmt.Errorf("not a windows machine")
+		return fmt.Errorf("not a windows machine")
 	}
 
 	spec := types.GuestProgramSpec{
@@ -474,14 +517,14 @@ func (c *Client) RunSimpleCommands(ctx context.Context, commands []string) error
 		EnvVariables:     nil,
 	}
 
-	pid, err := c.ProcessManager.StartProgram(ctx, c.Authentication, &spec)
+	ctx, pid, err := c.startProgram(ctx, &spec)
 	if err != nil {
 		return err
 	}
 
 	rc := 0
 	for {
-		procs, err := c.ProcessManager.ListProcesses(ctx, c.Authentication, []int64{pid})
+		procs, err := c.poll(ctx, pid)
 		if err != nil {
 			return err
 		}
@@ -497,13 +540,22 @@ func (c *Client) RunSimpleCommands(ctx context.Context, commands []string) error
 		break
 	}
 
+	logging.Debug(ctx, "guest program exited", logging.Fields{"exit_code": rc})
+
 	if rc != 0 {
-		return &exitError{fmt.Errorf("%s: exit %d", path, rc), rc}
+		return &ExitError{fmt.Errorf("%s: exit %d", path, rc), rc}
 	}
 
 	return nil
 }
 
+// scriptArguments runs file with its output written to outFile. powershell.exe treats the arguments as a
+// -Command pipeline and would only exit with 0 or 1, so the exit code of the script is passed on explicitly.
+func scriptArguments(file, outFile string) []string {
+	return []string{"-Command", "&", psQuote(file), "| Out-File", outFile, "-encoding ASCII;",
+		"$ok = $?; if ($LASTEXITCODE) { exit $LASTEXITCODE } elseif (-not $ok) { exit 1 }"}
+}
+
 // RunScript implements RunScript over vmx guest RPC against standard vmware-tools or toolbox.
 func (c *Client) RunScript(ctx context.Context, data chan string, script string) error {
 	defer close(data)
@@ -524,7 +576,6 @@ func (c *Client) RunScript(ctx context.Context, data chan string, script string)
 	err = c.Upload(ctx, fSrcScript, execfile, p, &types.GuestFileAttributes{}, true)
 
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
@@ -539,7 +590,7 @@ func (c *Client) RunScript(ctx context.Context, data chan string, script string)
 	switch c.GuestFamily {
 	case types.VirtualMachineGuestOsFamilyWindowsGuest:
 		path = "C:\\WINDOWS\\system32\\WindowsPowerShell\\v1.0\\powershell.exe"
-		args = []string{execfile, "| Out-File", outFile, "-encoding ASCII"}
+		args = scriptArguments(execfile, outFile)
 	default:
 		//if !strings.ContainsAny(cmd.Path, "/") {
 		//	// vmware-tools requires an absolute ProgramPath
@@ -560,7 +611,7 @@ func (c *Client) RunScript(ctx context.Context, data chan string, script string)
 
 	//fmt.Println(spec.ProgramPath,spec.Arguments)
 
-	pid, err := c.ProcessManager.StartProgram(ctx, c.Authentication, &spec)
+	ctx, pid, err := c.startProgram(ctx, &spec)
 	if err != nil {
 		return err
 	}
@@ -569,7 +620,7 @@ func (c *Client) RunScript(ctx context.Context, data chan string, script string)
 	var l int64 = 0
 
 	for {
-		procs, err := c.ProcessManager.ListProcesses(ctx, c.Authentication, []int64{pid})
+		procs, err := c.poll(ctx, pid)
 		if err != nil {
 			return err
 		}
@@ -606,8 +657,10 @@ func (c *Client) RunScript(ctx context.Context, data chan string, script string)
 	io.Copy(buf, f)
 	data <- buf.String()[l:n]
 
+	logging.Debug(ctx, "guest program exited", logging.Fields{"exit_code": rc})
+
 	if rc != 0 {
-		return &exitError{fmt.Errorf("%s: exit %d", path, rc), rc}
+		return &ExitError{fmt.Errorf("%s: exit %d", path, rc), rc}
 	}
 
 	//fmt.Println(buf.String())
@@ -655,7 +708,15 @@ func isDir(src string) bool {
 }
 
 // Download initiates a file transfer from the guest
-func (c *Client) Download(ctx context.Context, src string) (io.ReadCloser, int64, error) {
+func (c *Client) Download(ctx context.Context, src string) (_ io.ReadCloser, n int64, err error) {
+	ctx, op := telemetry.Start(ctx, "toolbox.Download")
+	defer func() {
+		if err == nil {
+			op.Transferred("download", n)
+		}
+		op.End(err)
+	}()
+
 	vc := c.ProcessManager.Client()
 
 	info, err := c.FileManager.InitiateFileTransferFromGuest(ctx, c.Authentication, src)
@@ -683,10 +744,16 @@ func (c *Client) Download(ctx context.Context, src string) (io.ReadCloser, int64
 }
 
 // Upload transfers a file to the guest
-func (c *Client) Upload(ctx context.Context, src io.Reader, dst string, p soap.Upload, attr types.BaseGuestFileAttributes, force bool) error {
-	vc := c.ProcessManager.Client()
+func (c *Client) Upload(ctx context.Context, src io.Reader, dst string, p soap.Upload, attr types.BaseGuestFileAttributes, force bool) (err error) {
+	ctx, op := telemetry.Start(ctx, "toolbox.Upload")
+	defer func() {
+		if err == nil {
+			op.Transferred("upload", p.ContentLength)
+		}
+		op.End(err)
+	}()
 
-	var err error
+	vc := c.ProcessManager.Client()
 
 	if p.ContentLength == 0 { // Content-Length is required
 		switch r := src.(type) {
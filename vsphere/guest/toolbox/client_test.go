@@ -0,0 +1,26 @@
+package toolbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptArgumentsPropagateExitCode(t *testing.T) {
+	args := strings.Join(scriptArguments(`C:\Temp\govmomi-1.ps1`, `C:\Temp\out.txt`), " ")
+
+	for _, want := range []string{
+		`-Command & 'C:\Temp\govmomi-1.ps1'`,
+		`| Out-File C:\Temp\out.txt`,
+		"exit $LASTEXITCODE",
+		"exit 1",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("arguments %q do not contain %q", args, want)
+		}
+	}
+
+	// the exit code check has to come after the pipeline, as its own statement
+	if strings.Index(args, "exit $LASTEXITCODE") < strings.Index(args, "Out-File") || !strings.Contains(args, "ASCII;") {
+		t.Errorf("arguments %q do not end the pipeline before exiting", args)
+	}
+}
@@ -0,0 +1,250 @@
+package toolbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ElevatedOptions controls how RunElevated schedules a script on a Windows guest.
+type ElevatedOptions struct {
+	// Interactive runs the task in the desktop session of User instead of as a batch job.
+	// The user has to be logged on for the task to start.
+	Interactive bool
+	// User and Password override the account the task runs as. When empty the credentials
+	// of the client's NamePasswordAuthentication are used. "SYSTEM" needs no password.
+	User     string
+	Password string
+	// TaskName defaults to a unique govmomi- prefixed name.
+	TaskName string
+	// PollInterval defaults to 2 seconds.
+	PollInterval time.Duration
+}
+
+// psQuote quotes s as a single quoted PowerShell string literal.
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// passwordVariable carries the task password to the launcher through its process environment, so that it
+// is never written to a file on the guest.
+const passwordVariable = "GOVMOMI_TASK_PASSWORD"
+
+func (c *Client) uploadString(ctx context.Context, content, dir, suffix string) (string, error) {
+	dst, err := c.FileManager.CreateTemporaryFile(ctx, c.Authentication, "govmomi-", suffix, dir)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.Upload(ctx, strings.NewReader(content), dst, soap.DefaultUpload, &types.GuestFileAttributes{}, true)
+	if err != nil {
+		c.rm(ctx, dst)
+		return "", err
+	}
+
+	return dst, nil
+}
+
+// detached keeps the values of a context but not its cancellation, for cleanup that has to run after ctx is done.
+type detached struct{ context.Context }
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// rmdir removes dir and everything in it, even if ctx was canceled.
+func (c *Client) rmdir(ctx context.Context, dir string) {
+	ctx, cancel := context.WithTimeout(detached{ctx}, time.Minute)
+	defer cancel()
+
+	if err := c.FileManager.DeleteDirectory(ctx, c.Authentication, dir, true); err != nil {
+		logging.Warn(ctx, "rmdir failed", logging.Fields{"path": dir, "error": err})
+	}
+}
+
+// waitAndStream polls pid until it exits, sending whatever was appended to outFile in the meantime to data.
+func (c *Client) waitAndStream(ctx context.Context, pid int64, outFile string, data chan string, interval time.Duration) (int, error) {
+	var l int64 = 0
+
+	for {
+		procs, err := c.poll(ctx, pid)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(procs) == 0 {
+			return 0, fmt.Errorf("process %d not found", pid)
+		}
+
+		p := procs[0]
+		done := p.EndTime != nil
+
+		if !done {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		var buf = new(strings.Builder)
+
+		f, _, err := c.Download(ctx, outFile)
+		if err != nil {
+			return 0, err
+		}
+
+		io.Copy(buf, f)
+		f.Close()
+
+		if n := int64(buf.Len()); n > l {
+			data <- buf.String()[l:n]
+			l = n
+		}
+
+		if done {
+			return int(p.ExitCode), nil
+		}
+	}
+}
+
+// RunElevated runs script through the Windows task scheduler with highest privileges, optionally in the
+// interactive session, so that installers needing a full admin token or a desktop work.
+// Output is streamed to data and the exit code of the script is returned as an exit error.
+func (c *Client) RunElevated(ctx context.Context, data chan string, script string, opts ElevatedOptions) error {
+	defer close(data)
+
+	if c.GuestFamily != types.VirtualMachineGuestOsFamilyWindowsGuest {
+		return fmt.Errorf("not a windows system")
+	}
+
+	user, password := opts.User, opts.Password
+	if user == "" {
+		if auth, ok := c.Authentication.(*types.NamePasswordAuthentication); ok {
+			user, password = auth.Username, auth.Password
+		}
+	}
+
+	if user == "" {
+		return fmt.Errorf("elevated execution requires a user")
+	}
+
+	if opts.PollInterval == 0 {
+		opts.PollInterval = time.Second * 2
+	}
+
+	// everything is staged in one directory, which the task user is granted access to and which is removed
+	// as a whole, also when the task fails
+	stage, err := c.FileManager.CreateTemporaryDirectory(ctx, c.Authentication, "govmomi-", "", "")
+	if err != nil {
+		return err
+	}
+	defer c.rmdir(ctx, stage)
+
+	scriptFile, err := c.uploadString(ctx, script, stage, ".ps1")
+	if err != nil {
+		return err
+	}
+
+	outFile, err := c.FileManager.CreateTemporaryFile(ctx, c.Authentication, "govmomi-", "", stage)
+	if err != nil {
+		return err
+	}
+
+	// the task writes its exit code here; the file must not exist before the task finishes
+	rcFile := outFile + ".rc"
+
+	wrapper := strings.Join([]string{
+		"$rc = 0",
+		"try {",
+		fmt.Sprintf("  & %s *>&1 | Out-File -FilePath %s -Encoding ASCII", psQuote(scriptFile), psQuote(outFile)),
+		"  if ($LASTEXITCODE) { $rc = $LASTEXITCODE } elseif (-not $?) { $rc = 1 }",
+		"} catch {",
+		fmt.Sprintf("  $_ | Out-File -FilePath %s -Append -Encoding ASCII", psQuote(outFile)),
+		"  $rc = 1",
+		"}",
+		fmt.Sprintf("Set-Content -Path %s -Value $rc -Encoding ASCII", psQuote(rcFile)),
+	}, "\r\n")
+
+	wrapperFile, err := c.uploadString(ctx, wrapper, stage, ".ps1")
+	if err != nil {
+		return err
+	}
+
+	taskName := opts.TaskName
+	if taskName == "" {
+		taskName = fmt.Sprintf("govmomi-%d", time.Now().UnixNano())
+	}
+
+	var env []string
+	var register string
+	switch {
+	case opts.Interactive:
+		register = fmt.Sprintf("$principal = New-ScheduledTaskPrincipal -UserId %s -LogonType Interactive -RunLevel Highest; "+
+			"Register-ScheduledTask -TaskName %s -Action $action -Principal $principal -Force | Out-Null",
+			psQuote(user), psQuote(taskName))
+	case strings.EqualFold(user, "SYSTEM"):
+		register = fmt.Sprintf("Register-ScheduledTask -TaskName %s -Action $action -User 'SYSTEM' -RunLevel Highest -Force | Out-Null",
+			psQuote(taskName))
+	default:
+		env = []string{passwordVariable + "=" + password}
+		register = fmt.Sprintf("$password = $env:%s; Remove-Item Env:\\%s; "+
+			"Register-ScheduledTask -TaskName %s -Action $action -User %s -Password $password -RunLevel Highest -Force | Out-Null",
+			passwordVariable, passwordVariable, psQuote(taskName), psQuote(user))
+	}
+
+	launcher := strings.Join([]string{
+		"$ErrorActionPreference = 'Stop'",
+		// the task user may not be able to read the temp directory of the user the launcher runs as
+		fmt.Sprintf("icacls %s /grant %s /T | Out-Null", psQuote(stage), psQuote(user+":(OI)(CI)M")),
+		fmt.Sprintf("$action = New-ScheduledTaskAction -Execute 'powershell.exe' -Argument %s",
+			psQuote(fmt.Sprintf(`-NoProfile -NonInteractive -ExecutionPolicy Bypass -File "%s"`, wrapperFile))),
+		register,
+		"try {",
+		fmt.Sprintf("  Start-ScheduledTask -TaskName %s", psQuote(taskName)),
+		fmt.Sprintf("  do { Start-Sleep -Seconds 2 } while (-not (Test-Path %s) -and (Get-ScheduledTask -TaskName %s).State -ne 'Ready')",
+			psQuote(rcFile), psQuote(taskName)),
+		"} finally {",
+		fmt.Sprintf("  Unregister-ScheduledTask -TaskName %s -Confirm:$false", psQuote(taskName)),
+		"}",
+		fmt.Sprintf("if (-not (Test-Path %s)) { exit 1 }", psQuote(rcFile)),
+		fmt.Sprintf("exit [int](Get-Content %s)", psQuote(rcFile)),
+	}, "\r\n")
+
+	launcherFile, err := c.uploadString(ctx, launcher, stage, ".ps1")
+	if err != nil {
+		return err
+	}
+
+	path := "C:\\WINDOWS\\system32\\WindowsPowerShell\\v1.0\\powershell.exe"
+	spec := types.GuestProgramSpec{
+		ProgramPath:      path,
+		Arguments:        strings.Join([]string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy Bypass", "-File", launcherFile}, " "),
+		WorkingDirectory: "",
+		EnvVariables:     env,
+	}
+
+	ctx, pid, err := c.startProgram(ctx, &spec)
+	if err != nil {
+		return err
+	}
+
+	rc, err := c.waitAndStream(ctx, pid, outFile, data, opts.PollInterval)
+	if err != nil {
+		return err
+	}
+
+	logging.Debug(ctx, "guest program exited", logging.Fields{"exit_code": rc, "task": taskName})
+
+	if rc != 0 {
+		return &ExitError{fmt.Errorf("%s: exit %d", taskName, rc), rc}
+	}
+
+	return nil
+}
@@ -2,17 +2,33 @@ package vsphere
 
 import (
 	"context"
-	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 )
 
-func GetHosts(ctx context.Context, c *vim25.Client, namepattern string) ([]mo.HostSystem, error) {
+// GetHosts returns the hosts whose name matches namepattern, with the properties selected by opts,
+// PropertiesFull by default. No match is not an error.
+func GetHosts(ctx context.Context, c *vim25.Client, namepattern string, opts ...InventoryOption) ([]mo.HostSystem, error) {
+
+	var o inventoryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ps, err := o.propertiesFor("HostSystem")
+	if err != nil {
+		return nil, err
+	}
 
 	m := view.NewManager(c)
 
-	v, err := m.CreateContainerView(ctx, c.ServiceContent.RootFolder, []string{"HostSystem"}, true)
+	root, err := o.root(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := m.CreateContainerView(ctx, root, []string{"HostSystem"}, true)
 	if err != nil {
 		return nil, err
 	}
@@ -21,7 +37,7 @@ func GetHosts(ctx context.Context, c *vim25.Client, namepattern string) ([]mo.Ho
 
 	var hostSystems []mo.HostSystem
 
-	err = v.RetrieveWithFilter(ctx, []string{"HostSystem"}, []string{"runtime","summary","hardware","datastore","network","config"}, &hostSystems, property.Filter{"name": namepattern})
+	err = retrieveByName(ctx, v, "HostSystem", ps, namepattern, &hostSystems)
 
 	if err != nil {
 		return nil, err
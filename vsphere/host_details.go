@@ -0,0 +1,351 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// hostDetailProperties are the properties NewHostDetails reads.
+var hostDetailProperties = []string{"name", "runtime", "summary", "hardware", "config"}
+
+// Keys of the host services HostDetails reports separately.
+const (
+	serviceNTP = "ntpd"
+	serviceSSH = "TSM-SSH"
+)
+
+// HostDetails is a flat, typed view of a host for compliance reports, built from a mo.HostSystem.
+type HostDetails struct {
+	Name  string `json:"name" yaml:"name"`
+	MoRef string `json:"moref" yaml:"moref"`
+
+	ConnectionState   string     `json:"connection_state" yaml:"connection_state"`
+	PowerState        string     `json:"power_state" yaml:"power_state"`
+	InMaintenanceMode bool       `json:"in_maintenance_mode" yaml:"in_maintenance_mode"`
+	RebootRequired    bool       `json:"reboot_required" yaml:"reboot_required"`
+	BootTime          *time.Time `json:"boot_time,omitempty" yaml:"boot_time,omitempty"`
+
+	Product string `json:"product,omitempty" yaml:"product,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Build   string `json:"build,omitempty" yaml:"build,omitempty"`
+
+	Vendor       string   `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Model        string   `json:"model,omitempty" yaml:"model,omitempty"`
+	SerialNumber string   `json:"serial_number,omitempty" yaml:"serial_number,omitempty"`
+	UUID         string   `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	BIOS         HostBIOS `json:"bios" yaml:"bios"`
+
+	CPU         HostCPU `json:"cpu" yaml:"cpu"`
+	MemoryBytes int64   `json:"memory_bytes" yaml:"memory_bytes"`
+
+	PhysicalNICs    []HostPhysicalNIC    `json:"physical_nics" yaml:"physical_nics"`
+	VMKernelNICs    []HostVMKernelNIC    `json:"vmkernel_nics" yaml:"vmkernel_nics"`
+	StorageAdapters []HostStorageAdapter `json:"storage_adapters" yaml:"storage_adapters"`
+	LUNs            []HostLUN            `json:"luns" yaml:"luns"`
+
+	NTP      HostNTP       `json:"ntp" yaml:"ntp"`
+	SSH      *HostService  `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	Services []HostService `json:"services" yaml:"services"`
+}
+
+type HostBIOS struct {
+	Vendor      string     `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Version     string     `json:"version,omitempty" yaml:"version,omitempty"`
+	ReleaseDate *time.Time `json:"release_date,omitempty" yaml:"release_date,omitempty"`
+}
+
+type HostCPU struct {
+	Model    string           `json:"model,omitempty" yaml:"model,omitempty"`
+	MHz      int32            `json:"mhz" yaml:"mhz"`
+	Packages int16            `json:"packages" yaml:"packages"`
+	Cores    int16            `json:"cores" yaml:"cores"`
+	Threads  int16            `json:"threads" yaml:"threads"`
+	Package  []HostCPUPackage `json:"package,omitempty" yaml:"package,omitempty"`
+}
+
+type HostCPUPackage struct {
+	Index       int16  `json:"index" yaml:"index"`
+	Vendor      string `json:"vendor" yaml:"vendor"`
+	Description string `json:"description" yaml:"description"`
+	Threads     int    `json:"threads" yaml:"threads"`
+}
+
+type HostPhysicalNIC struct {
+	Device string `json:"device" yaml:"device"`
+	Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+	PCI    string `json:"pci,omitempty" yaml:"pci,omitempty"`
+	MAC    string `json:"mac,omitempty" yaml:"mac,omitempty"`
+	// LinkUp is false if the NIC has no link, in which case SpeedMb and FullDuplex are zero.
+	LinkUp     bool  `json:"link_up" yaml:"link_up"`
+	SpeedMb    int32 `json:"speed_mb,omitempty" yaml:"speed_mb,omitempty"`
+	FullDuplex bool  `json:"full_duplex,omitempty" yaml:"full_duplex,omitempty"`
+}
+
+type HostVMKernelNIC struct {
+	Device string `json:"device" yaml:"device"`
+	// Portgroup is set for standard switches, DVPortgroupKey for distributed switches.
+	Portgroup      string `json:"portgroup,omitempty" yaml:"portgroup,omitempty"`
+	DVPortgroupKey string `json:"dv_portgroup_key,omitempty" yaml:"dv_portgroup_key,omitempty"`
+	MAC            string `json:"mac,omitempty" yaml:"mac,omitempty"`
+	MTU            int32  `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	DHCP           bool   `json:"dhcp" yaml:"dhcp"`
+	IPAddress      string `json:"ip_address,omitempty" yaml:"ip_address,omitempty"`
+	SubnetMask     string `json:"subnet_mask,omitempty" yaml:"subnet_mask,omitempty"`
+	TCPIPStack     string `json:"tcpip_stack,omitempty" yaml:"tcpip_stack,omitempty"`
+	// Services are the traffic types enabled on the NIC, e.g. management, vmotion or vsan.
+	Services []string `json:"services,omitempty" yaml:"services,omitempty"`
+}
+
+type HostStorageAdapter struct {
+	Device string `json:"device" yaml:"device"`
+	// Type is fibreChannel, iscsi, parallelScsi, blockHba, sas, pcie or other.
+	Type   string `json:"type" yaml:"type"`
+	Model  string `json:"model,omitempty" yaml:"model,omitempty"`
+	Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+	// WWPN and WWNN are set for Fibre Channel adapters, IQN for iSCSI adapters.
+	WWPN string `json:"wwpn,omitempty" yaml:"wwpn,omitempty"`
+	WWNN string `json:"wwnn,omitempty" yaml:"wwnn,omitempty"`
+	IQN  string `json:"iqn,omitempty" yaml:"iqn,omitempty"`
+}
+
+type HostLUN struct {
+	CanonicalName string `json:"canonical_name" yaml:"canonical_name"`
+	DisplayName   string `json:"display_name,omitempty" yaml:"display_name,omitempty"`
+	// Type is the SCSI device type, e.g. disk or cdrom.
+	Type             string   `json:"type" yaml:"type"`
+	Vendor           string   `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Model            string   `json:"model,omitempty" yaml:"model,omitempty"`
+	OperationalState []string `json:"operational_state,omitempty" yaml:"operational_state,omitempty"`
+	// CapacityBytes, SSD and Local are only known for disks.
+	CapacityBytes int64 `json:"capacity_bytes,omitempty" yaml:"capacity_bytes,omitempty"`
+	SSD           *bool `json:"ssd,omitempty" yaml:"ssd,omitempty"`
+	Local         *bool `json:"local,omitempty" yaml:"local,omitempty"`
+}
+
+type HostNTP struct {
+	Servers []string `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Running bool     `json:"running" yaml:"running"`
+	Policy  string   `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+type HostService struct {
+	Key     string `json:"key" yaml:"key"`
+	Label   string `json:"label" yaml:"label"`
+	Running bool   `json:"running" yaml:"running"`
+	// Policy is on, off or automatic.
+	Policy string `json:"policy" yaml:"policy"`
+}
+
+// formatWWN formats a Fibre Channel world wide name as colon separated hex bytes.
+func formatWWN(wwn int64) string {
+	s := fmt.Sprintf("%016x", uint64(wwn))
+
+	parts := make([]string, 0, 8)
+	for i := 0; i < len(s); i += 2 {
+		parts = append(parts, s[i:i+2])
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func newHostStorageAdapter(hba types.BaseHostHostBusAdapter) HostStorageAdapter {
+	h := hba.GetHostHostBusAdapter()
+
+	a := HostStorageAdapter{Device: h.Device, Type: "other", Model: h.Model, Driver: h.Driver, Status: h.Status}
+
+	switch x := hba.(type) {
+	case *types.HostFibreChannelOverEthernetHba:
+		a.Type, a.WWPN, a.WWNN = "fcoe", formatWWN(x.PortWorldWideName), formatWWN(x.NodeWorldWideName)
+	case *types.HostFibreChannelHba:
+		a.Type, a.WWPN, a.WWNN = "fibreChannel", formatWWN(x.PortWorldWideName), formatWWN(x.NodeWorldWideName)
+	case *types.HostInternetScsiHba:
+		a.Type, a.IQN = "iscsi", x.IScsiName
+	case *types.HostParallelScsiHba:
+		a.Type = "parallelScsi"
+	case *types.HostBlockHba:
+		a.Type = "blockHba"
+	case *types.HostSerialAttachedHba:
+		a.Type = "sas"
+	case *types.HostPcieHba:
+		a.Type = "pcie"
+	}
+
+	return a
+}
+
+func newHostLUN(lun types.BaseScsiLun) HostLUN {
+	l := lun.GetScsiLun()
+
+	hl := HostLUN{
+		CanonicalName:    l.CanonicalName,
+		DisplayName:      l.DisplayName,
+		Type:             l.LunType,
+		Vendor:           strings.TrimSpace(l.Vendor),
+		Model:            strings.TrimSpace(l.Model),
+		OperationalState: l.OperationalState,
+	}
+
+	if disk, ok := lun.(*types.HostScsiDisk); ok {
+		hl.CapacityBytes = int64(disk.Capacity.BlockSize) * disk.Capacity.Block
+		hl.SSD, hl.Local = disk.Ssd, disk.LocalDisk
+	}
+
+	return hl
+}
+
+// vmkernelServices maps vmkernel NIC keys to the traffic types selected for them.
+func vmkernelServices(info *types.HostVirtualNicManagerInfo) map[string][]string {
+	services := map[string][]string{}
+	if info == nil {
+		return services
+	}
+
+	for _, nc := range info.NetConfig {
+		for _, selected := range nc.SelectedVnic {
+			// selected is "<nicType>.<vnic key>"
+			key := strings.TrimPrefix(selected, nc.NicType+".")
+			services[key] = append(services[key], nc.NicType)
+		}
+	}
+
+	return services
+}
+
+// NewHostDetails builds the HostDetails of h. Properties that were not retrieved, or that a disconnected
+// host does not report, are left zero.
+func NewHostDetails(h *mo.HostSystem) *HostDetails {
+	d := HostDetails{
+		Name:              h.Name,
+		MoRef:             h.Reference().String(),
+		ConnectionState:   string(h.Runtime.ConnectionState),
+		PowerState:        string(h.Runtime.PowerState),
+		InMaintenanceMode: h.Runtime.InMaintenanceMode,
+		BootTime:          h.Runtime.BootTime,
+		RebootRequired:    h.Summary.RebootRequired,
+		PhysicalNICs:      []HostPhysicalNIC{},
+		VMKernelNICs:      []HostVMKernelNIC{},
+		StorageAdapters:   []HostStorageAdapter{},
+		LUNs:              []HostLUN{},
+		Services:          []HostService{},
+	}
+
+	if p := h.Summary.Config.Product; p != nil {
+		d.Product, d.Version, d.Build = p.FullName, p.Version, p.Build
+	}
+
+	if hw := h.Summary.Hardware; hw != nil {
+		d.CPU.Model, d.CPU.MHz = hw.CpuModel, hw.CpuMhz
+	}
+
+	if hw := h.Hardware; hw != nil {
+		d.Vendor, d.Model = hw.SystemInfo.Vendor, hw.SystemInfo.Model
+		d.SerialNumber, d.UUID = hw.SystemInfo.SerialNumber, hw.SystemInfo.Uuid
+		d.MemoryBytes = hw.MemorySize
+
+		d.CPU.Packages, d.CPU.Cores, d.CPU.Threads = hw.CpuInfo.NumCpuPackages, hw.CpuInfo.NumCpuCores, hw.CpuInfo.NumCpuThreads
+		for _, pkg := range hw.CpuPkg {
+			d.CPU.Package = append(d.CPU.Package, HostCPUPackage{
+				Index:       pkg.Index,
+				Vendor:      pkg.Vendor,
+				Description: pkg.Description,
+				Threads:     len(pkg.ThreadId),
+			})
+		}
+
+		if bios := hw.BiosInfo; bios != nil {
+			d.BIOS = HostBIOS{Vendor: bios.Vendor, Version: bios.BiosVersion, ReleaseDate: bios.ReleaseDate}
+		}
+	}
+
+	config := h.Config
+	if config == nil {
+		return &d
+	}
+
+	if network := config.Network; network != nil {
+		for _, pnic := range network.Pnic {
+			nic := HostPhysicalNIC{Device: pnic.Device, Driver: pnic.Driver, PCI: pnic.Pci, MAC: pnic.Mac}
+			if link := pnic.LinkSpeed; link != nil {
+				nic.LinkUp, nic.SpeedMb, nic.FullDuplex = true, link.SpeedMb, link.Duplex
+			}
+			d.PhysicalNICs = append(d.PhysicalNICs, nic)
+		}
+
+		services := vmkernelServices(config.VirtualNicManagerInfo)
+
+		for _, vnic := range network.Vnic {
+			nic := HostVMKernelNIC{
+				Device:     vnic.Device,
+				Portgroup:  vnic.Portgroup,
+				MAC:        vnic.Spec.Mac,
+				MTU:        vnic.Spec.Mtu,
+				TCPIPStack: vnic.Spec.NetStackInstanceKey,
+				Services:   services[vnic.Key],
+			}
+			if ip := vnic.Spec.Ip; ip != nil {
+				nic.DHCP, nic.IPAddress, nic.SubnetMask = ip.Dhcp, ip.IpAddress, ip.SubnetMask
+			}
+			if port := vnic.Spec.DistributedVirtualPort; port != nil {
+				nic.DVPortgroupKey = port.PortgroupKey
+			}
+			d.VMKernelNICs = append(d.VMKernelNICs, nic)
+		}
+	}
+
+	if storage := config.StorageDevice; storage != nil {
+		for _, hba := range storage.HostBusAdapter {
+			d.StorageAdapters = append(d.StorageAdapters, newHostStorageAdapter(hba))
+		}
+
+		for _, lun := range storage.ScsiLun {
+			d.LUNs = append(d.LUNs, newHostLUN(lun))
+		}
+	}
+
+	if dt := config.DateTimeInfo; dt != nil && dt.NtpConfig != nil {
+		d.NTP.Servers = dt.NtpConfig.Server
+	}
+
+	if service := config.Service; service != nil {
+		for _, s := range service.Service {
+			hs := HostService{Key: s.Key, Label: s.Label, Running: s.Running, Policy: s.Policy}
+
+			switch s.Key {
+			case serviceNTP:
+				d.NTP.Running, d.NTP.Policy = s.Running, s.Policy
+			case serviceSSH:
+				ssh := hs
+				d.SSH = &ssh
+			}
+
+			d.Services = append(d.Services, hs)
+		}
+	}
+
+	return &d
+}
+
+// GetHostDetails returns the HostDetails of the hosts whose name matches namepattern. opts scope the query
+// as for GetHosts; the properties are always those NewHostDetails needs.
+func GetHostDetails(ctx context.Context, c *vim25.Client, namepattern string, opts ...InventoryOption) ([]HostDetails, error) {
+	opts = appendOpts(opts, WithProperties(hostDetailProperties...))
+
+	hosts, err := GetHosts(ctx, c, namepattern, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]HostDetails, len(hosts))
+	for i := range hosts {
+		details[i] = *NewHostDetails(&hosts[i])
+	}
+
+	return details, nil
+}
@@ -0,0 +1,104 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestFormatWWN(t *testing.T) {
+	tests := []struct {
+		wwn  int64
+		want string
+	}{
+		{0, "00:00:00:00:00:00:00:00"},
+		{0x2000001b32a1b2c3, "20:00:00:1b:32:a1:b2:c3"},
+		// WWNs with the high bit set come back from the API as negative numbers
+		{-0x5fffffe4cd5e4d3d, "a0:00:00:1b:32:a1:b2:c3"},
+		{-1, "ff:ff:ff:ff:ff:ff:ff:ff"},
+	}
+
+	for _, tt := range tests {
+		if got := formatWWN(tt.wwn); got != tt.want {
+			t.Errorf("formatWWN(%#x) = %s, want %s", tt.wwn, got, tt.want)
+		}
+	}
+}
+
+func TestVMKernelServices(t *testing.T) {
+	tests := []struct {
+		name string
+		info *types.HostVirtualNicManagerInfo
+		want map[string][]string
+	}{
+		{"nil", nil, map[string][]string{}},
+		{"none selected", &types.HostVirtualNicManagerInfo{NetConfig: []types.VirtualNicManagerNetConfig{{NicType: "vmotion"}}}, map[string][]string{}},
+		{"several", &types.HostVirtualNicManagerInfo{NetConfig: []types.VirtualNicManagerNetConfig{
+			{NicType: "management", SelectedVnic: []string{"management.key-vim.host.VirtualNic-vmk0"}},
+			{NicType: "vmotion", SelectedVnic: []string{"vmotion.key-vim.host.VirtualNic-vmk1"}},
+			{NicType: "vSphereProvisioning", SelectedVnic: []string{"vSphereProvisioning.key-vim.host.VirtualNic-vmk0", "vSphereProvisioning.key-vim.host.VirtualNic-vmk1"}},
+		}}, map[string][]string{
+			"key-vim.host.VirtualNic-vmk0": {"management", "vSphereProvisioning"},
+			"key-vim.host.VirtualNic-vmk1": {"vmotion", "vSphereProvisioning"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := vmkernelServices(tt.info); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: vmkernelServices = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewHostDetailsNoConfig(t *testing.T) {
+	var h mo.HostSystem
+	h.Name = "esx1"
+	h.Self = types.ManagedObjectReference{Type: "HostSystem", Value: "host-1"}
+	h.Runtime.ConnectionState = types.HostSystemConnectionStateDisconnected
+
+	d := NewHostDetails(&h)
+
+	if d.Name != "esx1" || d.MoRef != "HostSystem:host-1" || d.ConnectionState != "disconnected" {
+		t.Errorf("details = %+v", d)
+	}
+
+	// lists are empty rather than nil so they encode as []
+	if d.PhysicalNICs == nil || d.VMKernelNICs == nil || d.StorageAdapters == nil || d.LUNs == nil || d.Services == nil {
+		t.Errorf("details of a host without config have nil lists: %+v", d)
+	}
+
+	if d.SSH != nil || d.Product != "" {
+		t.Errorf("details of a host without config report %+v", d)
+	}
+}
+
+func TestGetHostDetails(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		// spare capacity that appending to opts would write into
+		opts := make([]InventoryOption, 0, 4)
+
+		details, err := GetHostDetails(ctx, c, "*", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opts[:1][0] != nil {
+			t.Error("GetHostDetails wrote into the caller's options")
+		}
+
+		if len(details) == 0 {
+			t.Fatal("no host details")
+		}
+
+		for _, d := range details {
+			if d.Name == "" || d.Product == "" || len(d.PhysicalNICs) == 0 {
+				t.Errorf("incomplete details %+v", d)
+			}
+		}
+	})
+}
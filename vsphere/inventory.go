@@ -0,0 +1,541 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Filter selects virtual machines or hosts. Every field that is set has to match, as well as all of And
+// and, if not empty, at least one of Or. The zero Filter matches everything.
+//
+// Folder, ResourcePool, Cluster, Host, Datastore, Network and Tags are inventory paths or names (tag
+// names or IDs) and are resolved server-side to the objects they contain; only the candidates left after
+// the top level ones are intersected have their properties retrieved.
+type Filter struct {
+	// Name is a glob matched against the object name, like the namepattern of GetVirtualMachines.
+	Name string
+	// PowerState is poweredOn, poweredOff or suspended for VMs, and poweredOn, poweredOff or standBy for hosts.
+	PowerState string
+	// GuestFamily is the guest.guestFamily reported by VMware Tools, e.g. windowsGuest or just windows.
+	// It does not apply to hosts.
+	GuestFamily string
+
+	Folder       string
+	ResourcePool string
+	Cluster      string
+	Host         string
+	Datastore    string
+	Network      string
+
+	// CustomAttributes maps custom attribute names to the exact value they must have.
+	CustomAttributes map[string]string
+	// Tags must all be attached to the object. Needs WithTagManager.
+	Tags []string
+
+	And []Filter
+	Or  []Filter
+}
+
+type inventoryOptions struct {
+	tags       *tags.Manager
+	preset     PropertyPreset
+	properties []string
+	scope      string
+}
+
+// InventoryOption configures GetVirtualMachines, GetHosts, FindVirtualMachines, FindHosts and GetComputeClusters.
+type InventoryOption func(*inventoryOptions)
+
+// WithTagManager gives the inventory functions the vAPI tag manager needed to evaluate Filter.Tags.
+func WithTagManager(m *tags.Manager) InventoryOption {
+	return func(o *inventoryOptions) {
+		o.tags = m
+	}
+}
+
+// appendOpts returns opts followed by more. opts is copied first, appending to it directly could
+// write into the array of a caller that passed its own slice with spare capacity.
+func appendOpts(opts []InventoryOption, more ...InventoryOption) []InventoryOption {
+	return append(append([]InventoryOption(nil), opts...), more...)
+}
+
+type refSet map[types.ManagedObjectReference]bool
+
+// candidate is what a Filter is evaluated against, for both VMs and hosts.
+type candidate struct {
+	ref         types.ManagedObjectReference
+	name        string
+	powerState  string
+	guestFamily string
+	customValue []types.BaseCustomFieldValue
+}
+
+type filterEval struct {
+	c    *vim25.Client
+	kind string
+	opts inventoryOptions
+
+	sets   map[string]refSet
+	fields map[string]int32
+}
+
+func newFilterEval(c *vim25.Client, kind string, opts []InventoryOption) *filterEval {
+	e := &filterEval{c: c, kind: kind, sets: map[string]refSet{}}
+
+	for _, opt := range opts {
+		opt(&e.opts)
+	}
+
+	return e
+}
+
+// members returns the objects of e.kind that a scope term of the filter selects.
+func (e *filterEval) members(ctx context.Context, term, value string) (refSet, error) {
+	key := term + "\x00" + value
+	if set, ok := e.sets[key]; ok {
+		return set, nil
+	}
+
+	finder := find.NewFinder(e.c)
+
+	var (
+		refs []types.ManagedObjectReference
+		err  error
+	)
+
+	switch term {
+	case "folder":
+		var f *object.Folder
+		if f, err = finder.Folder(ctx, value); err == nil {
+			refs, err = e.contained(ctx, f.Reference())
+		}
+	case "resource pool":
+		if e.kind != "VirtualMachine" {
+			return nil, fmt.Errorf("resource pool filter does not apply to %s", e.kind)
+		}
+		var p *object.ResourcePool
+		if p, err = finder.ResourcePool(ctx, value); err == nil {
+			refs, err = e.contained(ctx, p.Reference())
+		}
+	case "cluster":
+		var cr *object.ClusterComputeResource
+		if cr, err = finder.ClusterComputeResource(ctx, value); err == nil {
+			refs, err = e.contained(ctx, cr.Reference())
+		}
+	case "host":
+		var h *object.HostSystem
+		if h, err = finder.HostSystem(ctx, value); err == nil {
+			if e.kind == "HostSystem" {
+				refs = []types.ManagedObjectReference{h.Reference()}
+			} else {
+				refs, err = e.contained(ctx, h.Reference())
+			}
+		}
+	case "datastore":
+		var ds *object.Datastore
+		if ds, err = finder.Datastore(ctx, value); err == nil {
+			var m mo.Datastore
+			if err = ds.Properties(ctx, ds.Reference(), []string{"vm", "host"}, &m); err == nil {
+				refs = m.Vm
+				if e.kind == "HostSystem" {
+					refs = nil
+					for _, mount := range m.Host {
+						refs = append(refs, mount.Key)
+					}
+				}
+			}
+		}
+	case "network":
+		var n object.NetworkReference
+		if n, err = finder.Network(ctx, value); err == nil {
+			var m mo.Network
+			if err = property.DefaultCollector(e.c).RetrieveOne(ctx, n.Reference(), []string{"vm", "host"}, &m); err == nil {
+				refs = m.Vm
+				if e.kind == "HostSystem" {
+					refs = m.Host
+				}
+			}
+		}
+	case "scope":
+		var root types.ManagedObjectReference
+		if root, err = e.opts.root(ctx, e.c); err == nil {
+			refs, err = e.contained(ctx, root)
+		}
+	case "tag":
+		if e.opts.tags == nil {
+			return nil, fmt.Errorf("tag filter requires WithTagManager")
+		}
+		var attached []mo.Reference
+		if attached, err = e.opts.tags.ListAttachedObjects(ctx, value); err == nil {
+			for _, ref := range attached {
+				refs = append(refs, ref.Reference())
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s filter %s: %s", term, value, err)
+	}
+
+	set := refSet{}
+	for _, ref := range refs {
+		if ref.Type == e.kind {
+			set[ref] = true
+		}
+	}
+
+	e.sets[key] = set
+
+	return set, nil
+}
+
+// contained lists the objects of e.kind below container, using a container view.
+func (e *filterEval) contained(ctx context.Context, container types.ManagedObjectReference) ([]types.ManagedObjectReference, error) {
+	v, err := view.NewManager(e.c).CreateContainerView(ctx, container, []string{e.kind}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer v.Destroy(ctx)
+
+	return v.Find(ctx, []string{e.kind}, nil)
+}
+
+func (e *filterEval) fieldKey(ctx context.Context, name string) (int32, error) {
+	if e.fields == nil {
+		m, err := object.GetCustomFieldsManager(e.c)
+		if err != nil {
+			return 0, err
+		}
+
+		defs, err := m.Field(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		e.fields = map[string]int32{}
+		for _, def := range defs {
+			if def.ManagedObjectType == "" || def.ManagedObjectType == e.kind {
+				e.fields[def.Name] = def.Key
+			}
+		}
+	}
+
+	key, ok := e.fields[name]
+	if !ok {
+		return 0, fmt.Errorf("custom attribute %q not found", name)
+	}
+
+	return key, nil
+}
+
+// scopes returns the scope terms of f, which are evaluated as sets of members.
+func scopes(f *Filter) [][2]string {
+	var terms [][2]string
+
+	for _, t := range [][2]string{
+		{"folder", f.Folder}, {"resource pool", f.ResourcePool}, {"cluster", f.Cluster},
+		{"host", f.Host}, {"datastore", f.Datastore}, {"network", f.Network},
+	} {
+		if t[1] != "" {
+			terms = append(terms, t)
+		}
+	}
+
+	for _, tag := range f.Tags {
+		terms = append(terms, [2]string{"tag", tag})
+	}
+
+	return terms
+}
+
+// usesCustomAttributes reports whether f or any of its sub-filters needs the customValue property.
+func usesCustomAttributes(f *Filter) bool {
+	if len(f.CustomAttributes) != 0 {
+		return true
+	}
+
+	for _, sub := range append(append([]Filter(nil), f.And...), f.Or...) {
+		if usesCustomAttributes(&sub) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// candidates returns the objects selected by the top level scope terms of f, nil meaning all of them.
+func (e *filterEval) candidates(ctx context.Context, f *Filter) ([]types.ManagedObjectReference, error) {
+	var set refSet
+
+	for _, t := range scopes(f) {
+		members, err := e.members(ctx, t[0], t[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if set == nil {
+			set = members
+			continue
+		}
+
+		next := refSet{}
+		for ref := range set {
+			if members[ref] {
+				next[ref] = true
+			}
+		}
+		set = next
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	refs := []types.ManagedObjectReference{}
+	for ref := range set {
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+func matchName(pattern, name string) bool {
+	if pattern == "*" {
+		return true
+	}
+	m, _ := path.Match(pattern, name)
+	return m
+}
+
+func (e *filterEval) match(ctx context.Context, f *Filter, c *candidate) (bool, error) {
+	if f.Name != "" && !matchName(f.Name, c.name) {
+		return false, nil
+	}
+
+	if f.PowerState != "" && !strings.EqualFold(f.PowerState, c.powerState) {
+		return false, nil
+	}
+
+	if f.GuestFamily != "" {
+		if e.kind != "VirtualMachine" {
+			return false, fmt.Errorf("guest family filter does not apply to %s", e.kind)
+		}
+		if !strings.EqualFold(f.GuestFamily, c.guestFamily) && !strings.EqualFold(f.GuestFamily+"Guest", c.guestFamily) {
+			return false, nil
+		}
+	}
+
+	for _, t := range scopes(f) {
+		members, err := e.members(ctx, t[0], t[1])
+		if err != nil {
+			return false, err
+		}
+		if !members[c.ref] {
+			return false, nil
+		}
+	}
+
+	for name, value := range f.CustomAttributes {
+		key, err := e.fieldKey(ctx, name)
+		if err != nil {
+			return false, err
+		}
+
+		found := false
+		for _, v := range c.customValue {
+			if s, ok := v.(*types.CustomFieldStringValue); ok && s.Key == key && s.Value == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	for i := range f.And {
+		ok, err := e.match(ctx, &f.And[i], c)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if len(f.Or) == 0 {
+		return true, nil
+	}
+
+	for i := range f.Or {
+		ok, err := e.match(ctx, &f.Or[i], c)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// withProperties adds the properties in extra to ps unless ps already has them or one of their parents.
+func withProperties(ps []string, extra ...string) []string {
+	ps = append([]string(nil), ps...)
+
+next:
+	for _, x := range extra {
+		for _, p := range ps {
+			if p == x || strings.HasPrefix(x, p+".") {
+				continue next
+			}
+		}
+		ps = append(ps, x)
+	}
+
+	return ps
+}
+
+// retrieveByName loads the properties ps of the objects of kind in v whose name matches namepattern into dst.
+// No match leaves dst empty, where the property collector would fail on the empty list of objects.
+func retrieveByName(ctx context.Context, v *view.ContainerView, kind string, ps []string, namepattern string, dst interface{}) error {
+	refs, err := v.Find(ctx, []string{kind}, property.Filter{"name": namepattern})
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+
+	return property.DefaultCollector(v.Client()).Retrieve(ctx, refs, ps, dst)
+}
+
+// retrieve loads the selected properties of the candidates of f into dst, a pointer to a slice of
+// mo.VirtualMachine or mo.HostSystem, along with what is needed to evaluate f.
+func (e *filterEval) retrieve(ctx context.Context, f *Filter, dst interface{}) error {
+	ps, err := e.opts.propertiesFor(e.kind)
+	if err != nil {
+		return err
+	}
+
+	ps = withProperties(ps, "name", "runtime.powerState")
+	if e.kind == "VirtualMachine" {
+		ps = withProperties(ps, "guest.guestFamily")
+	}
+	if usesCustomAttributes(f) {
+		ps = withProperties(ps, "customValue")
+	}
+
+	refs, err := e.candidates(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if refs != nil {
+		if e.opts.scope != "" {
+			scoped, err := e.members(ctx, "scope", e.opts.scope)
+			if err != nil {
+				return err
+			}
+
+			var in []types.ManagedObjectReference
+			for _, ref := range refs {
+				if scoped[ref] {
+					in = append(in, ref)
+				}
+			}
+			refs = in
+		}
+
+		if len(refs) == 0 {
+			return nil
+		}
+		return property.DefaultCollector(e.c).Retrieve(ctx, refs, ps, dst)
+	}
+
+	root, err := e.opts.root(ctx, e.c)
+	if err != nil {
+		return err
+	}
+
+	v, err := view.NewManager(e.c).CreateContainerView(ctx, root, []string{e.kind}, true)
+	if err != nil {
+		return err
+	}
+
+	defer v.Destroy(ctx)
+
+	return v.Retrieve(ctx, []string{e.kind}, ps, dst)
+}
+
+// FindVirtualMachines returns the VMs matching filter, nil matching all of them. The properties
+// needed to evaluate filter are retrieved in addition to those selected by opts.
+func FindVirtualMachines(ctx context.Context, c *vim25.Client, filter *Filter, opts ...InventoryOption) ([]mo.VirtualMachine, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+
+	e := newFilterEval(c, "VirtualMachine", opts)
+
+	var vms []mo.VirtualMachine
+
+	err := e.retrieve(ctx, filter, &vms)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []mo.VirtualMachine
+
+	for _, vm := range vms {
+		cand := &candidate{ref: vm.Reference(), name: vm.Name, powerState: string(vm.Runtime.PowerState), customValue: vm.CustomValue}
+		if vm.Guest != nil {
+			cand.guestFamily = vm.Guest.GuestFamily
+		}
+
+		ok, err := e.match(ctx, filter, cand)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, vm)
+		}
+	}
+
+	return matched, nil
+}
+
+// FindHosts returns the hosts matching filter, nil matching all of them, see FindVirtualMachines.
+func FindHosts(ctx context.Context, c *vim25.Client, filter *Filter, opts ...InventoryOption) ([]mo.HostSystem, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+
+	e := newFilterEval(c, "HostSystem", opts)
+
+	var hosts []mo.HostSystem
+
+	err := e.retrieve(ctx, filter, &hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []mo.HostSystem
+
+	for _, host := range hosts {
+		cand := &candidate{ref: host.Reference(), name: host.Name, powerState: string(host.Runtime.PowerState), customValue: host.CustomValue}
+
+		ok, err := e.match(ctx, filter, cand)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, host)
+		}
+	}
+
+	return matched, nil
+}
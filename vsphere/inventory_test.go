@@ -0,0 +1,117 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestFilterMatch(t *testing.T) {
+	vm := func(id, name, power, family, env string) *candidate {
+		c := &candidate{
+			ref:         types.ManagedObjectReference{Type: "VirtualMachine", Value: id},
+			name:        name,
+			powerState:  power,
+			guestFamily: family,
+		}
+		if env != "" {
+			c.customValue = []types.BaseCustomFieldValue{&types.CustomFieldStringValue{CustomFieldValue: types.CustomFieldValue{Key: 1}, Value: env}}
+		}
+		return c
+	}
+
+	candidates := []*candidate{
+		vm("vm-1", "web-1", "poweredOn", "windowsGuest", "prod"),
+		vm("vm-2", "web-2", "poweredOff", "windowsGuest", "test"),
+		vm("vm-3", "db-1", "poweredOn", "linuxGuest", "prod"),
+		vm("vm-4", "db-2", "poweredOn", "linuxGuest", ""),
+	}
+
+	set := func(ids ...string) refSet {
+		s := refSet{}
+		for _, id := range ids {
+			s[types.ManagedObjectReference{Type: "VirtualMachine", Value: id}] = true
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    []string
+		wantErr bool
+	}{
+		{name: "zero filter", want: []string{"web-1", "web-2", "db-1", "db-2"}},
+		{name: "top level terms are and-ed", filter: Filter{Name: "web-*", PowerState: "poweredOn"}, want: []string{"web-1"}},
+		{name: "and", filter: Filter{And: []Filter{{Name: "db-*"}, {Cluster: "C1"}}}, want: []string{"db-1"}},
+		{name: "or", filter: Filter{Or: []Filter{{Name: "web-2"}, {GuestFamily: "linux"}}}, want: []string{"web-2", "db-1", "db-2"}},
+		{name: "or with top level terms", filter: Filter{PowerState: "poweredOn", Or: []Filter{{Name: "web-2"}, {GuestFamily: "linux"}}}, want: []string{"db-1", "db-2"}},
+		{
+			name: "or of ands",
+			filter: Filter{Or: []Filter{
+				{And: []Filter{{GuestFamily: "windows"}, {PowerState: "poweredOff"}}},
+				{And: []Filter{{GuestFamily: "linux"}, {CustomAttributes: map[string]string{"env": "prod"}}}},
+			}},
+			want: []string{"web-2", "db-1"},
+		},
+		{
+			name: "and of ors",
+			filter: Filter{And: []Filter{
+				{Or: []Filter{{Name: "web-*"}, {Folder: "/DC0/vm/databases"}}},
+				{Or: []Filter{{Cluster: "C1"}, {PowerState: "poweredOff"}}},
+			}},
+			want: []string{"web-1", "web-2", "db-1"},
+		},
+		{
+			name: "nested three deep",
+			filter: Filter{And: []Filter{{Or: []Filter{
+				{And: []Filter{{Name: "db-*"}, {Or: []Filter{{CustomAttributes: map[string]string{"env": "prod"}}, {Cluster: "C2"}}}}},
+			}}}},
+			want: []string{"db-1", "db-2"},
+		},
+		{name: "empty and", filter: Filter{And: []Filter{}}, want: []string{"web-1", "web-2", "db-1", "db-2"}},
+		{name: "or of nothing matching", filter: Filter{Or: []Filter{{Name: "app-*"}, {PowerState: "suspended"}}}, want: nil},
+		{name: "error in and", filter: Filter{And: []Filter{{CustomAttributes: map[string]string{"owner": "me"}}}}, wantErr: true},
+		{name: "error in or", filter: Filter{Or: []Filter{{Name: "app-*"}, {CustomAttributes: map[string]string{"owner": "me"}}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// members and fields are cached, so filled in up front no vCenter is needed
+			e := &filterEval{
+				kind: "VirtualMachine",
+				sets: map[string]refSet{
+					"cluster\x00C1":               set("vm-1", "vm-3"),
+					"cluster\x00C2":               set("vm-2", "vm-4"),
+					"folder\x00/DC0/vm/databases": set("vm-3", "vm-4"),
+				},
+				fields: map[string]int32{"env": 1},
+			}
+
+			var got []string
+
+			for _, c := range candidates {
+				ok, err := e.match(context.Background(), &tt.filter, c)
+				if err != nil {
+					if !tt.wantErr {
+						t.Fatalf("match(%s): %s", c.name, err)
+					}
+					return
+				}
+				if ok {
+					got = append(got, c.name)
+				}
+			}
+
+			if tt.wantErr {
+				t.Fatal("match did not fail")
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package vsphere
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit caps the SOAP calls of the client at rps per second, allowing bursts of up to burst calls.
+func WithRateLimit(rps float64, burst int) ClientOption {
+	return func(o *clientOptions) {
+		if burst < 1 {
+			burst = 1
+		}
+		o.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// WithMaxInFlight caps the number of SOAP calls of the client waiting for a response at the same time.
+// Long polling WaitForUpdates calls do not count against it.
+func WithMaxInFlight(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxInFlight = n
+	}
+}
+
+// limiter throttles every request passed to the wrapped round tripper, including retries.
+type limiter struct {
+	roundTripper soap.RoundTripper
+	rate         *rate.Limiter
+	inFlight     chan struct{}
+}
+
+// RoundTrip implements soap.RoundTripper
+func (l *limiter) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	if l.inFlight != nil {
+		switch methodName(req) {
+		case "WaitForUpdates", "WaitForUpdatesEx":
+		default:
+			select {
+			case l.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-l.inFlight }()
+		}
+	}
+
+	return l.roundTripper.RoundTrip(ctx, req, res)
+}
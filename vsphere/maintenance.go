@@ -0,0 +1,322 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/roshankarande/utils/telemetry"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/progress"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel/label"
+)
+
+// HostOperation names a host lifecycle operation in HostProgress reports, telemetry and errors.
+type HostOperation string
+
+const (
+	// HostEnterMaintenance is EnterMaintenanceMode.
+	HostEnterMaintenance = HostOperation("enterMaintenanceMode")
+	// HostExitMaintenance is ExitMaintenanceMode.
+	HostExitMaintenance = HostOperation("exitMaintenanceMode")
+	// HostReboot is RebootHost, including the wait for the host to come back.
+	HostReboot = HostOperation("reboot")
+	// HostShutdown is ShutdownHost.
+	HostShutdown = HostOperation("shutdown")
+	// HostDisconnect is DisconnectHost.
+	HostDisconnect = HostOperation("disconnect")
+	// HostReconnect is ReconnectHost, including the wait for the host to be connected.
+	HostReconnect = HostOperation("reconnect")
+	// HostWaitReady is WaitForHostReady.
+	HostWaitReady = HostOperation("waitReady")
+)
+
+// HostProgress is reported while a host operation runs.
+type HostProgress struct {
+	Host      string
+	Operation HostOperation
+	// Percent is the progress of the vSphere task, 0 to 100.
+	Percent int32
+	// ConnectionState is set instead of Percent while waiting for the host to come back.
+	ConnectionState string
+}
+
+// HostOptions controls the host operations.
+type HostOptions struct {
+	// Timeout for the whole operation, including waiting for the host to come back. Defaults to 30 minutes.
+	Timeout time.Duration
+	// Progress is called with task progress and connection state changes.
+	Progress func(p HostProgress)
+}
+
+// MaintenanceSpec controls how EnterMaintenanceMode evacuates the host. Powered-on VMs are only migrated
+// off the host in fully automated DRS clusters; elsewhere the task waits for them to be moved or powered
+// off until the timeout.
+type MaintenanceSpec struct {
+	// EvacuatePoweredOffVMs also moves powered-off and suspended VMs, in DRS clusters only.
+	EvacuatePoweredOffVMs bool
+	// VSANMode is what happens to vSAN data on the host: ensureObjectAccessibility, evacuateAllData or
+	// noAction. Empty leaves it to vCenter.
+	VSANMode string
+}
+
+func (o HostOptions) progress(ctx context.Context, p HostProgress) {
+	logging.Debug(ctx, "host operation progress", logging.Fields{"percent": p.Percent, "connection_state": p.ConnectionState})
+	if o.Progress != nil {
+		o.Progress(p)
+	}
+}
+
+// hostName names host by its inventory name, or by its moref when it was not looked up through the inventory.
+func hostName(host *object.HostSystem) string {
+	if name := host.Name(); name != "" {
+		return name
+	}
+
+	return host.Reference().Value
+}
+
+// runHostOperation runs fn for op under the timeout of opts, with telemetry and errors naming the host.
+func runHostOperation(ctx context.Context, host *object.HostSystem, op HostOperation, opts HostOptions, fn func(ctx context.Context, o HostOptions) error) (err error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Minute * 30
+	}
+
+	name := hostName(host)
+
+	ctx, span := telemetry.Start(ctx, "vsphere.Host."+string(op))
+	defer func() { span.End(err) }()
+
+	span.SetAttributes(label.String("host", name), label.String("host_id", host.Reference().Value))
+
+	ctx = logging.WithFields(ctx, logging.Fields{"host": name, "host_id": host.Reference().Value, "operation": op})
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	if err = fn(ctx, opts); err != nil {
+		return fmt.Errorf("%s %s: %s", op, name, err)
+	}
+
+	return nil
+}
+
+// waitTask waits for task, reporting its progress.
+func (o HostOptions) waitTask(ctx context.Context, host *object.HostSystem, op HostOperation, task *object.Task) error {
+	reports := make(chan progress.Report)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for r := range reports {
+			o.progress(ctx, HostProgress{Host: hostName(host), Operation: op, Percent: int32(r.Percentage())})
+		}
+	}()
+
+	_, err := task.WaitForResult(ctx, progress.SinkFunc(func() chan<- progress.Report { return reports }))
+	<-done
+
+	return err
+}
+
+// waitConnected waits until host is connected and powered on and, if bootTime is given, has booted since.
+func (o HostOptions) waitConnected(ctx context.Context, host *object.HostSystem, op HostOperation, bootTime *time.Time) error {
+	var (
+		state   types.HostSystemConnectionState
+		power   types.HostSystemPowerState
+		booted  = bootTime == nil
+		current string
+	)
+
+	err := property.Wait(ctx, property.DefaultCollector(host.Client()), host.Reference(), []string{"runtime.connectionState", "runtime.powerState", "runtime.bootTime"}, func(pcs []types.PropertyChange) bool {
+		for _, c := range pcs {
+			switch c.Name {
+			case "runtime.connectionState":
+				state, _ = c.Val.(types.HostSystemConnectionState)
+			case "runtime.powerState":
+				power, _ = c.Val.(types.HostSystemPowerState)
+			case "runtime.bootTime":
+				if t, ok := c.Val.(time.Time); ok && bootTime != nil {
+					booted = t.After(*bootTime)
+				}
+			}
+		}
+
+		if string(state) != current {
+			current = string(state)
+			o.progress(ctx, HostProgress{Host: hostName(host), Operation: op, ConnectionState: current})
+		}
+
+		return booted && state == types.HostSystemConnectionStateConnected && power == types.HostSystemPowerStatePoweredOn
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for host: %s", err)
+	}
+
+	return nil
+}
+
+func hostRuntime(ctx context.Context, host *object.HostSystem) (*types.HostRuntimeInfo, error) {
+	var h mo.HostSystem
+
+	if err := host.Properties(ctx, host.Reference(), []string{"runtime"}, &h); err != nil {
+		return nil, err
+	}
+
+	return &h.Runtime, nil
+}
+
+// EnterMaintenanceMode puts host into maintenance mode, evacuating it as spec says. A host that already is
+// in maintenance mode is left alone.
+func EnterMaintenanceMode(ctx context.Context, host *object.HostSystem, spec MaintenanceSpec, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostEnterMaintenance, opts, func(ctx context.Context, o HostOptions) error {
+		runtime, err := hostRuntime(ctx, host)
+		if err != nil {
+			return err
+		}
+
+		if runtime.InMaintenanceMode {
+			return nil
+		}
+
+		var ms *types.HostMaintenanceSpec
+		if spec.VSANMode != "" {
+			ms = &types.HostMaintenanceSpec{VsanMode: &types.VsanHostDecommissionMode{ObjectAction: spec.VSANMode}}
+		}
+
+		task, err := host.EnterMaintenanceMode(ctx, int32(o.Timeout.Seconds()), spec.EvacuatePoweredOffVMs, ms)
+		if err != nil {
+			return err
+		}
+
+		return o.waitTask(ctx, host, HostEnterMaintenance, task)
+	})
+}
+
+// ExitMaintenanceMode takes host out of maintenance mode. A host that is not in maintenance mode is left alone.
+func ExitMaintenanceMode(ctx context.Context, host *object.HostSystem, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostExitMaintenance, opts, func(ctx context.Context, o HostOptions) error {
+		runtime, err := hostRuntime(ctx, host)
+		if err != nil {
+			return err
+		}
+
+		if !runtime.InMaintenanceMode {
+			return nil
+		}
+
+		task, err := host.ExitMaintenanceMode(ctx, int32(o.Timeout.Seconds()))
+		if err != nil {
+			return err
+		}
+
+		return o.waitTask(ctx, host, HostExitMaintenance, task)
+	})
+}
+
+// RebootHost reboots host and waits until it has booted and is connected again. Without force the host has
+// to be in maintenance mode.
+func RebootHost(ctx context.Context, host *object.HostSystem, force bool, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostReboot, opts, func(ctx context.Context, o HostOptions) error {
+		runtime, err := hostRuntime(ctx, host)
+		if err != nil {
+			return err
+		}
+
+		res, err := methods.RebootHost_Task(ctx, host.Client(), &types.RebootHost_Task{This: host.Reference(), Force: force})
+		if err != nil {
+			return err
+		}
+
+		if err = o.waitTask(ctx, host, HostReboot, object.NewTask(host.Client(), res.Returnval)); err != nil {
+			return err
+		}
+
+		return o.waitRebooted(ctx, host, HostReboot, runtime.BootTime)
+	})
+}
+
+// waitRebooted waits until host has rebooted and is connected again. Without a boot time to compare to,
+// the host has to drop its connection to vCenter first.
+func (o HostOptions) waitRebooted(ctx context.Context, host *object.HostSystem, op HostOperation, bootTime *time.Time) error {
+	if bootTime == nil {
+		if err := o.waitDisconnected(ctx, host, op); err != nil {
+			return err
+		}
+	}
+
+	return o.waitConnected(ctx, host, op, bootTime)
+}
+
+// waitDisconnected waits until host is no longer connected to vCenter.
+func (o HostOptions) waitDisconnected(ctx context.Context, host *object.HostSystem, op HostOperation) error {
+	err := property.Wait(ctx, property.DefaultCollector(host.Client()), host.Reference(), []string{"runtime.connectionState"}, func(pcs []types.PropertyChange) bool {
+		for _, c := range pcs {
+			if state, ok := c.Val.(types.HostSystemConnectionState); ok && state != types.HostSystemConnectionStateConnected {
+				o.progress(ctx, HostProgress{Host: hostName(host), Operation: op, ConnectionState: string(state)})
+				return true
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for host to go down: %s", err)
+	}
+
+	return nil
+}
+
+// ShutdownHost shuts host down. Without force the host has to be in maintenance mode.
+func ShutdownHost(ctx context.Context, host *object.HostSystem, force bool, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostShutdown, opts, func(ctx context.Context, o HostOptions) error {
+		res, err := methods.ShutdownHost_Task(ctx, host.Client(), &types.ShutdownHost_Task{This: host.Reference(), Force: force})
+		if err != nil {
+			return err
+		}
+
+		return o.waitTask(ctx, host, HostShutdown, object.NewTask(host.Client(), res.Returnval))
+	})
+}
+
+// DisconnectHost disconnects host from vCenter. Its VMs keep running.
+func DisconnectHost(ctx context.Context, host *object.HostSystem, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostDisconnect, opts, func(ctx context.Context, o HostOptions) error {
+		task, err := host.Disconnect(ctx)
+		if err != nil {
+			return err
+		}
+
+		return o.waitTask(ctx, host, HostDisconnect, task)
+	})
+}
+
+// ReconnectHost reconnects host to vCenter with the credentials vCenter already has, and waits until it
+// is connected.
+func ReconnectHost(ctx context.Context, host *object.HostSystem, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostReconnect, opts, func(ctx context.Context, o HostOptions) error {
+		task, err := host.Reconnect(ctx, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		if err = o.waitTask(ctx, host, HostReconnect, task); err != nil {
+			return err
+		}
+
+		return o.waitConnected(ctx, host, HostReconnect, nil)
+	})
+}
+
+// WaitForHostReady waits until host is powered on and connected to vCenter, e.g. after it was powered on
+// out of band.
+func WaitForHostReady(ctx context.Context, host *object.HostSystem, opts HostOptions) error {
+	return runHostOperation(ctx, host, HostWaitReady, opts, func(ctx context.Context, o HostOptions) error {
+		return o.waitConnected(ctx, host, HostWaitReady, nil)
+	})
+}
@@ -0,0 +1,132 @@
+package vsphere
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		host, err := find.NewFinder(c).HostSystem(ctx, "DC0_C0/DC0_C0_H0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var reports []HostProgress
+		opts := HostOptions{Timeout: time.Minute, Progress: func(p HostProgress) { reports = append(reports, p) }}
+
+		inMaintenance := func() bool {
+			runtime, err := hostRuntime(ctx, host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return runtime.InMaintenanceMode
+		}
+
+		for i := 0; i < 2; i++ {
+			if err = EnterMaintenanceMode(ctx, host, MaintenanceSpec{}, opts); err != nil {
+				t.Fatalf("EnterMaintenanceMode: %s", err)
+			}
+			if !inMaintenance() {
+				t.Fatal("host not in maintenance mode")
+			}
+		}
+
+		for i := 0; i < 2; i++ {
+			if err = ExitMaintenanceMode(ctx, host, opts); err != nil {
+				t.Fatalf("ExitMaintenanceMode: %s", err)
+			}
+			if inMaintenance() {
+				t.Fatal("host still in maintenance mode")
+			}
+		}
+
+		if len(reports) == 0 {
+			t.Error("no progress reported")
+		}
+		for _, p := range reports {
+			if p.Host != "DC0_C0_H0" {
+				t.Errorf("progress reported for host %q, want its name", p.Host)
+			}
+		}
+	})
+}
+
+func TestHostName(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		host, err := find.NewFinder(c).HostSystem(ctx, "DC0_C0/DC0_C0_H0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name := hostName(host); name != "DC0_C0_H0" {
+			t.Errorf("hostName = %q, want DC0_C0_H0", name)
+		}
+
+		ref := object.NewHostSystem(c, host.Reference())
+		if name := hostName(ref); name != host.Reference().Value {
+			t.Errorf("hostName = %q, want moref %s", name, host.Reference().Value)
+		}
+
+		// errors name the host the caller looked up
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		err = WaitForHostReady(cancelled, host, HostOptions{})
+		if err == nil || !strings.Contains(err.Error(), "DC0_C0_H0") {
+			t.Errorf("WaitForHostReady error = %v, want it to name the host", err)
+		}
+	})
+}
+
+func TestWaitRebooted(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		host, err := find.NewFinder(c).HostSystem(ctx, "DC0_C0/DC0_C0_H0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		obj := simulator.Map.Get(host.Reference()).(*simulator.HostSystem)
+		setState := func(state types.HostSystemConnectionState) {
+			simulator.Map.WithLock(obj, func() {
+				simulator.Map.Update(obj, []types.PropertyChange{{Name: "runtime.connectionState", Val: state}})
+			})
+		}
+
+		var states []string
+		opts := HostOptions{Progress: func(p HostProgress) { states = append(states, p.ConnectionState) }}
+
+		// without a boot time, a host that never went down has not rebooted yet
+		short, cancel := context.WithTimeout(ctx, time.Millisecond*200)
+		defer cancel()
+		if err = opts.waitRebooted(short, host, HostReboot, nil); err == nil {
+			t.Fatal("waitRebooted returned while the host stayed connected")
+		}
+
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			setState(types.HostSystemConnectionStateNotResponding)
+			time.Sleep(time.Millisecond * 100)
+			setState(types.HostSystemConnectionStateConnected)
+		}()
+
+		states = nil
+		wait, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		if err = opts.waitRebooted(wait, host, HostReboot, nil); err != nil {
+			t.Fatalf("waitRebooted: %s", err)
+		}
+
+		if len(states) < 2 || states[0] != string(types.HostSystemConnectionStateNotResponding) ||
+			states[len(states)-1] != string(types.HostSystemConnectionStateConnected) {
+			t.Errorf("connection states = %v, want notResponding then connected", states)
+		}
+	})
+}
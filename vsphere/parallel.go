@@ -0,0 +1,192 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/roshankarande/utils/vsphere/guest/toolbox"
+	"github.com/vmware/govmomi/guest"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Target is a VM to run a Job against, with the guest credentials to use for it.
+type Target struct {
+	Name string
+	VM   *object.VirtualMachine
+	Auth types.BaseGuestAuthentication
+}
+
+// Job runs against a single guest. Like the toolbox.Client Run* methods it may close data when done; output
+// sent after the job returned is not read.
+type Job func(ctx context.Context, c *toolbox.Client, data chan string) error
+
+func CommandsJob(commands []string) Job {
+	return func(ctx context.Context, c *toolbox.Client, data chan string) error {
+		return c.RunCommands(ctx, data, commands)
+	}
+}
+
+func ScriptJob(script string) Job {
+	return func(ctx context.Context, c *toolbox.Client, data chan string) error {
+		return c.RunScript(ctx, data, script)
+	}
+}
+
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+type Results []Result
+
+// Failed returns the results of the targets the job failed on.
+func (r Results) Failed() Results {
+	var failed Results
+
+	for _, res := range r {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+
+	return failed
+}
+
+// Err returns nil if the job succeeded everywhere, otherwise an error listing every failed target.
+func (r Results) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(failed))
+	for i, res := range failed {
+		msgs[i] = fmt.Sprintf("%s: %s", res.Name, res.Err)
+	}
+
+	return fmt.Errorf("%d of %d failed: %s", len(failed), len(r), strings.Join(msgs, "; "))
+}
+
+// TargetsFromPattern resolves namepattern with GetVirtualMachines into targets sharing auth.
+func TargetsFromPattern(ctx context.Context, c *vim25.Client, namepattern string, auth types.BaseGuestAuthentication) ([]Target, error) {
+	vms, err := GetVirtualMachines(ctx, c, namepattern, WithPropertyPreset(PropertiesMinimal))
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, len(vms))
+	for i, vm := range vms {
+		targets[i] = Target{
+			Name: vm.Name,
+			VM:   object.NewVirtualMachine(c, vm.Reference()),
+			Auth: auth,
+		}
+	}
+
+	return targets, nil
+}
+
+// InvokeParallel runs job against every target with at most concurrency jobs in flight. Output is sent to data
+// line by line, prefixed with the target name. A failing target does not stop the others; the returned
+// results are in the same order as targets.
+func InvokeParallel(ctx context.Context, targets []Target, data chan string, job Job, concurrency int) Results {
+	if data != nil {
+		defer close(data)
+	}
+
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+
+	results := make(Results, len(targets))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i := range targets {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			t := targets[i]
+			results[i].Name = t.Name
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			results[i].Err = invokeJob(ctx, t, data, job)
+			results[i].Duration = time.Since(start)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func invokeJob(ctx context.Context, t Target, data chan string, job Job) error {
+	ctx = logging.WithFields(ctx, logging.Fields{"vm": t.Name})
+
+	c, err := newToolboxClient(ctx, t.Auth, guest.NewOperationsManager(t.VM.Client(), t.VM.Reference()))
+	if err != nil {
+		return err
+	}
+
+	out := make(chan string)
+	finished := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		partial := ""
+		defer func() {
+			if partial != "" && data != nil {
+				data <- fmt.Sprintf("[%s] %s\n", t.Name, partial)
+			}
+		}()
+
+		for {
+			var s string
+			var ok bool
+
+			// a job that does not close out has nothing left to send once it returned
+			select {
+			case s, ok = <-out:
+				if !ok {
+					return
+				}
+			case <-finished:
+				return
+			}
+
+			lines := strings.Split(partial+s, "\n")
+			partial = lines[len(lines)-1]
+			for _, line := range lines[:len(lines)-1] {
+				if data != nil {
+					data <- fmt.Sprintf("[%s] %s\n", t.Name, strings.TrimRight(line, "\r"))
+				}
+			}
+		}
+	}()
+
+	err = job(ctx, c, out)
+	close(finished)
+	<-done
+
+	return err
+}
@@ -0,0 +1,72 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roshankarande/utils/vsphere/guest/toolbox"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestInvokeParallelJobClosing(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		vm, err := find.NewFinder(c).VirtualMachine(ctx, "DC0_H0_VM0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		target := Target{Name: "vm0", VM: vm, Auth: &types.NamePasswordAuthentication{Username: "u", Password: "p"}}
+		failure := errors.New("failed")
+
+		tests := []struct {
+			name  string
+			close bool
+		}{
+			{"closes data", true},
+			{"leaves data open", false},
+		}
+
+		for _, tt := range tests {
+			job := func(ctx context.Context, _ *toolbox.Client, data chan string) error {
+				if tt.close {
+					defer close(data)
+				}
+				data <- "line 1\r\nline"
+				data <- " 2"
+				return failure
+			}
+
+			data := make(chan string)
+			var lines []string
+			collected := make(chan struct{})
+
+			go func() {
+				defer close(collected)
+				for s := range data {
+					lines = append(lines, s)
+				}
+			}()
+
+			resc := make(chan Results, 1)
+			go func() { resc <- InvokeParallel(ctx, []Target{target}, data, job, 1) }()
+
+			select {
+			case res := <-resc:
+				<-collected
+				if res[0].Err != failure {
+					t.Errorf("%s: error = %v, want %v", tt.name, res[0].Err, failure)
+				}
+				if len(lines) != 2 || lines[0] != "[vm0] line 1\n" || lines[1] != "[vm0] line 2\n" {
+					t.Errorf("%s: output = %q", tt.name, lines)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatalf("%s: InvokeParallel did not return", tt.name)
+			}
+		}
+	})
+}
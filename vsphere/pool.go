@@ -0,0 +1,331 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// ClientPool holds one client per vCenter, connecting lazily on first use. Clients are safe to share
+// between goroutines.
+type ClientPool struct {
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+}
+
+type poolEntry struct {
+	// mu serializes connecting and logging out.
+	mu     sync.Mutex
+	config *Config
+
+	// state guards client and connecting. It is never held while talking to vCenter, so Health
+	// does not wait for a connection in progress.
+	state      sync.Mutex
+	client     *govmomi.Client
+	connecting bool
+}
+
+func (e *poolEntry) status() (c *govmomi.Client, connecting bool) {
+	e.state.Lock()
+	defer e.state.Unlock()
+
+	return e.client, e.connecting
+}
+
+func (e *poolEntry) set(c *govmomi.Client, connecting bool) {
+	e.state.Lock()
+	e.client, e.connecting = c, connecting
+	e.state.Unlock()
+}
+
+// PoolError maps vCenter names to the error that occurred on them.
+type PoolError map[string]error
+
+func (e PoolError) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %s", name, e[name])
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// NewClientPool creates a pool keyed by Config.Name, or Config.URL for unnamed configs. Two configs with
+// the same key are an error.
+func NewClientPool(configs ...*Config) (*ClientPool, error) {
+	p := &ClientPool{entries: map[string]*poolEntry{}}
+
+	for _, c := range configs {
+		name := c.Name
+		if name == "" {
+			name = c.URL
+		}
+		if _, ok := p.entries[name]; ok {
+			return nil, fmt.Errorf("vcenter %q is in the pool more than once", name)
+		}
+		p.entries[name] = &poolEntry{config: c}
+	}
+
+	return p, nil
+}
+
+// NewClientPoolFromFile creates a pool with every profile in f.
+func NewClientPoolFromFile(f *ConfigFile) (*ClientPool, error) {
+	configs := make([]*Config, 0, len(f.Profiles))
+	for _, c := range f.Profiles {
+		configs = append(configs, c)
+	}
+
+	return NewClientPool(configs...)
+}
+
+// Names returns the vCenter names in the pool, sorted.
+func (p *ClientPool) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.entries))
+	for name := range p.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (p *ClientPool) entry(name string) (*poolEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("vcenter %q not in pool", name)
+	}
+
+	return e, nil
+}
+
+// Client returns the client for the vCenter name, connecting first if needed.
+// A failed connection is retried on the next call.
+func (p *ClientPool) Client(ctx context.Context, name string) (*govmomi.Client, error) {
+	e, err := p.entry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c, _ := e.status(); c != nil {
+		return c, nil
+	}
+
+	e.set(nil, true)
+
+	c, err := e.config.NewClient(ctx)
+	e.set(c, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Health of a vCenter in the pool.
+type Health struct {
+	Name      string
+	Connected bool
+	// Connecting is set while the first connection, or a retry, is in progress.
+	Connecting bool
+	Err        error
+}
+
+// Health reports for every vCenter whether it is connected and its session is still valid.
+// It does not connect vCenters that have not been used yet, nor wait for those connecting.
+func (p *ClientPool) Health(ctx context.Context) []Health {
+	names := p.Names()
+	health := make([]Health, len(names))
+
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		health[i].Name = name
+
+		e, _ := p.entry(name)
+
+		c, connecting := e.status()
+		health[i].Connecting = connecting
+
+		if c == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(h *Health) {
+			defer wg.Done()
+
+			s, err := c.SessionManager.UserSession(ctx)
+			switch {
+			case err != nil:
+				h.Err = err
+			case s == nil:
+				h.Err = fmt.Errorf("not authenticated")
+			default:
+				h.Connected = true
+			}
+		}(&health[i])
+	}
+
+	wg.Wait()
+
+	return health
+}
+
+// Each calls f concurrently for every vCenter in the pool, connecting as needed.
+// Errors are collected per vCenter in a PoolError rather than stopping the others.
+func (p *ClientPool) Each(ctx context.Context, f func(ctx context.Context, name string, c *govmomi.Client) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = PoolError{}
+	)
+
+	for _, name := range p.Names() {
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			c, err := p.Client(ctx, name)
+			if err == nil {
+				err = f(ctx, name, c)
+			}
+
+			if err != nil {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}(name)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Logout ends the sessions of all connected vCenters.
+func (p *ClientPool) Logout(ctx context.Context) error {
+	errs := PoolError{}
+
+	for _, name := range p.Names() {
+		e, _ := p.entry(name)
+
+		e.mu.Lock()
+		if c, _ := e.status(); c != nil {
+			if err := c.Logout(ctx); err != nil {
+				errs[name] = err
+			}
+			e.set(nil, false)
+		}
+		e.mu.Unlock()
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// PoolVirtualMachine is a VM tagged with the vCenter it was found on.
+type PoolVirtualMachine struct {
+	VCenter string
+	mo.VirtualMachine
+}
+
+// PoolHostSystem is a host tagged with the vCenter it was found on.
+type PoolHostSystem struct {
+	VCenter string
+	mo.HostSystem
+}
+
+// GetVirtualMachines runs GetVirtualMachines on every vCenter. The VMs of the vCenters that succeeded
+// are returned sorted by vCenter and name, along with a PoolError for those that failed.
+func (p *ClientPool) GetVirtualMachines(ctx context.Context, namepattern string, opts ...InventoryOption) ([]PoolVirtualMachine, error) {
+	var (
+		mu  sync.Mutex
+		res []PoolVirtualMachine
+	)
+
+	err := p.Each(ctx, func(ctx context.Context, name string, c *govmomi.Client) error {
+		vms, err := GetVirtualMachines(ctx, c.Client, namepattern, opts...)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		for _, vm := range vms {
+			res = append(res, PoolVirtualMachine{VCenter: name, VirtualMachine: vm})
+		}
+		mu.Unlock()
+
+		return nil
+	})
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].VCenter != res[j].VCenter {
+			return res[i].VCenter < res[j].VCenter
+		}
+		return res[i].Name < res[j].Name
+	})
+
+	return res, err
+}
+
+// GetHosts runs GetHosts on every vCenter, see GetVirtualMachines.
+func (p *ClientPool) GetHosts(ctx context.Context, namepattern string, opts ...InventoryOption) ([]PoolHostSystem, error) {
+	var (
+		mu  sync.Mutex
+		res []PoolHostSystem
+	)
+
+	err := p.Each(ctx, func(ctx context.Context, name string, c *govmomi.Client) error {
+		hosts, err := GetHosts(ctx, c.Client, namepattern, opts...)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		for _, host := range hosts {
+			res = append(res, PoolHostSystem{VCenter: name, HostSystem: host})
+		}
+		mu.Unlock()
+
+		return nil
+	})
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].VCenter != res[j].VCenter {
+			return res[i].VCenter < res[j].VCenter
+		}
+		return res[i].Name < res[j].Name
+	})
+
+	return res, err
+}
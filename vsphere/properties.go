@@ -0,0 +1,69 @@
+package vsphere
+
+import (
+	"fmt"
+)
+
+// PropertyPreset names a set of properties for the inventory functions to retrieve. Properties that were
+// not retrieved are left zero or nil in the returned mo.VirtualMachine and mo.HostSystem values.
+type PropertyPreset string
+
+const (
+	// PropertiesMinimal is the name and power state, enough to pick targets.
+	PropertiesMinimal = PropertyPreset("minimal")
+	// PropertiesSummary adds the summary, which covers most reporting needs at a fraction of the cost of config.
+	PropertiesSummary = PropertyPreset("summary")
+	// PropertiesFull is what GetVirtualMachines and GetHosts have always retrieved, and stays their default.
+	PropertiesFull = PropertyPreset("full")
+)
+
+var presetProperties = map[string]map[PropertyPreset][]string{
+	"VirtualMachine": {
+		PropertiesMinimal: {"name", "runtime.powerState"},
+		PropertiesSummary: {"name", "summary"},
+		PropertiesFull:    {"name", "summary", "guest", "datastore", "network", "runtime", "guestHeartbeatStatus", "storage", "config"},
+	},
+	"HostSystem": {
+		PropertiesMinimal: {"name", "runtime.powerState", "runtime.connectionState"},
+		PropertiesSummary: {"name", "summary"},
+		PropertiesFull:    {"name", "runtime", "summary", "hardware", "datastore", "network", "config"},
+	},
+}
+
+// Properties returns the property paths p retrieves for objects of kind, VirtualMachine or HostSystem.
+func (p PropertyPreset) Properties(kind string) ([]string, error) {
+	ps, ok := presetProperties[kind][p]
+	if !ok {
+		return nil, fmt.Errorf("unknown property preset %q for %s", p, kind)
+	}
+
+	return ps, nil
+}
+
+// WithPropertyPreset selects the properties to retrieve by preset.
+func WithPropertyPreset(p PropertyPreset) InventoryOption {
+	return func(o *inventoryOptions) {
+		o.preset = p
+		o.properties = nil
+	}
+}
+
+// WithProperties retrieves exactly the property paths ps, e.g. "name", "summary.quickStats" or "runtime.host".
+func WithProperties(ps ...string) InventoryOption {
+	return func(o *inventoryOptions) {
+		o.properties = ps
+	}
+}
+
+// propertiesFor returns the properties selected by o for kind, PropertiesFull if none were.
+func (o *inventoryOptions) propertiesFor(kind string) ([]string, error) {
+	if len(o.properties) != 0 {
+		return o.properties, nil
+	}
+
+	if o.preset == "" {
+		return PropertiesFull.Properties(kind)
+	}
+
+	return o.preset.Properties(kind)
+}
@@ -0,0 +1,119 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// pathRecorder records the property paths requested for objects of kind, in the last retrieval that asked
+// for more than their name; looking objects up by name asks for just that.
+type pathRecorder struct {
+	roundTripper soap.RoundTripper
+	kind         string
+	paths        []string
+}
+
+func (r *pathRecorder) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	var specs []types.PropertyFilterSpec
+
+	switch body := req.(type) {
+	case *methods.RetrievePropertiesExBody:
+		specs = body.Req.SpecSet
+	case *methods.RetrievePropertiesBody:
+		specs = body.Req.SpecSet
+	}
+
+	for _, spec := range specs {
+		for _, ps := range spec.PropSet {
+			if ps.Type == r.kind && !reflect.DeepEqual(ps.PathSet, []string{"name"}) {
+				r.paths = append([]string(nil), ps.PathSet...)
+				sort.Strings(r.paths)
+			}
+		}
+	}
+
+	return r.roundTripper.RoundTrip(ctx, req, res)
+}
+
+func TestPropertyPresets(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		rt := c.RoundTripper
+
+		// the properties FindVirtualMachines and FindHosts need to evaluate a filter
+		evalProperties := map[string][]string{
+			"VirtualMachine": {"name", "runtime.powerState", "guest.guestFamily"},
+			"HostSystem":     {"name", "runtime.powerState"},
+		}
+
+		for _, kind := range []string{"VirtualMachine", "HostSystem"} {
+			for _, preset := range []PropertyPreset{PropertiesMinimal, PropertiesSummary, PropertiesFull} {
+				want, err := preset.Properties(kind)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				get := func() error {
+					if kind == "VirtualMachine" {
+						_, err := GetVirtualMachines(ctx, c, "*", WithPropertyPreset(preset))
+						return err
+					}
+					_, err := GetHosts(ctx, c, "*", WithPropertyPreset(preset))
+					return err
+				}
+
+				find := func() error {
+					if kind == "VirtualMachine" {
+						_, err := FindVirtualMachines(ctx, c, nil, WithPropertyPreset(preset))
+						return err
+					}
+					_, err := FindHosts(ctx, c, nil, WithPropertyPreset(preset))
+					return err
+				}
+
+				for _, q := range []struct {
+					name string
+					run  func() error
+					want []string
+				}{
+					{"get", get, want},
+					{"find", find, withProperties(want, evalProperties[kind]...)},
+				} {
+					rec := &pathRecorder{roundTripper: rt, kind: kind}
+					c.RoundTripper = rec
+
+					err := q.run()
+					c.RoundTripper = rt
+					if err != nil {
+						t.Fatalf("%s %s %s: %s", q.name, kind, preset, err)
+					}
+
+					wantPaths := append([]string(nil), q.want...)
+					sort.Strings(wantPaths)
+
+					if !reflect.DeepEqual(rec.paths, wantPaths) {
+						t.Errorf("%s %s %s: retrieved %v, want %v", q.name, kind, preset, rec.paths, wantPaths)
+					}
+				}
+			}
+		}
+
+		// properties that were not retrieved stay zero
+		vms, err := GetVirtualMachines(ctx, c, "*", WithPropertyPreset(PropertiesMinimal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vm := range vms {
+			if vm.Runtime.PowerState == "" || vm.Config != nil || vm.Summary.Config.Name != "" {
+				t.Errorf("%s: minimal preset retrieved more or less than the power state", vm.Name)
+			}
+		}
+	})
+}
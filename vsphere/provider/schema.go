@@ -0,0 +1,166 @@
+// Package provider maps the Terraform provider block for connecting to a vCenter to a vsphere.Config.
+// It is kept apart from helper so that helper does not depend on vsphere.
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/roshankarande/utils/logging"
+	"github.com/roshankarande/utils/vsphere"
+)
+
+// SchemaVSphereSpec is the provider block for connecting to a vCenter, defaulting to the
+// environment variables used by the terraform vsphere provider and govc.
+func SchemaVSphereSpec() map[string]*schema.Schema {
+	s := map[string]*schema.Schema{
+		"vsphere_server": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "vCenter server URL or host name",
+			DefaultFunc: schema.MultiEnvDefaultFunc([]string{"VSPHERE_SERVER", "GOVC_URL"}, nil),
+		},
+		"user": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "User name for vCenter API operations",
+			DefaultFunc: schema.MultiEnvDefaultFunc([]string{"VSPHERE_USER", "GOVC_USERNAME"}, ""),
+		},
+		"password": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+			Description: "Password for vCenter API operations",
+			DefaultFunc: schema.MultiEnvDefaultFunc([]string{"VSPHERE_PASSWORD", "GOVC_PASSWORD"}, ""),
+		},
+		"allow_unverified_ssl": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Skip verification of the vCenter certificate",
+			DefaultFunc: schema.MultiEnvDefaultFunc([]string{"VSPHERE_ALLOW_UNVERIFIED_SSL", "GOVC_INSECURE"}, false),
+		},
+		"ca_file": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "PEM file with the CA certificates to trust",
+			DefaultFunc: schema.EnvDefaultFunc("GOVC_TLS_CA_CERTS", ""),
+		},
+		"known_hosts_file": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "govc known_hosts file with the thumbprints of vCenter and ESX hosts",
+			DefaultFunc: schema.EnvDefaultFunc("GOVC_TLS_KNOWN_HOSTS", ""),
+		},
+		"thumbprint": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "SHA-1 or SHA-256 thumbprint the vCenter certificate is pinned to",
+			Default:     "",
+		},
+		"persist_session": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Cache the session on disk and reuse it across runs",
+			DefaultFunc: schema.MultiEnvDefaultFunc([]string{"VSPHERE_PERSIST_SESSION", "GOVC_PERSIST_SESSION"}, false),
+		},
+		"session_dir": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Directory for the session cache",
+			Default:     "",
+		},
+		"keep_alive": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Keep-alive interval such as 10m, empty to disable",
+			Default:     "",
+		},
+		"requests_per_second": {
+			Type:        schema.TypeFloat,
+			Optional:    true,
+			Description: "Maximum SOAP calls per second, 0 for unlimited",
+			Default:     0.0,
+		},
+		"max_in_flight": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "Maximum concurrent SOAP calls, 0 for unlimited",
+			Default:     0,
+		},
+		"soap_trace": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Log redacted SOAP bodies at debug level",
+			Default:     false,
+		},
+		"telemetry": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Report a span and metrics for every SOAP call",
+			DefaultFunc: schema.EnvDefaultFunc("VSPHERE_TELEMETRY", false),
+		},
+		"auth": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Login method: password, sts, token or session",
+			DefaultFunc: schema.EnvDefaultFunc("VSPHERE_AUTH", vsphere.AuthPassword),
+		},
+		"certificate": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Client certificate file for holder-of-key SAML tokens",
+			DefaultFunc: schema.EnvDefaultFunc("GOVC_CERTIFICATE", ""),
+		},
+		"private_key": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Private key file of the client certificate",
+			DefaultFunc: schema.EnvDefaultFunc("GOVC_PRIVATE_KEY", ""),
+		},
+		"token": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+			Description: "SAML token to log in with when auth is token",
+			DefaultFunc: schema.EnvDefaultFunc("VSPHERE_TOKEN", ""),
+		},
+		"session_id": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+			Description: "Existing session to reuse when auth is session",
+			DefaultFunc: schema.EnvDefaultFunc("VSPHERE_SESSION_ID", ""),
+		},
+	}
+
+	return s
+}
+
+// VSphereConfig reads a provider block declared with SchemaVSphereSpec.
+func VSphereConfig(d *schema.ResourceData) (*vsphere.Config, error) {
+	c := &vsphere.Config{
+		URL:               d.Get("vsphere_server").(string),
+		Username:          d.Get("user").(string),
+		Password:          d.Get("password").(string),
+		Insecure:          d.Get("allow_unverified_ssl").(bool),
+		CAFile:            d.Get("ca_file").(string),
+		KnownHostsFile:    d.Get("known_hosts_file").(string),
+		Thumbprint:        d.Get("thumbprint").(string),
+		PersistSession:    d.Get("persist_session").(bool),
+		SessionDir:        d.Get("session_dir").(string),
+		KeepAlive:         d.Get("keep_alive").(string),
+		RequestsPerSecond: d.Get("requests_per_second").(float64),
+		MaxInFlight:       d.Get("max_in_flight").(int),
+		SOAPTrace:         d.Get("soap_trace").(bool),
+		Telemetry:         d.Get("telemetry").(bool),
+		Auth:              d.Get("auth").(string),
+		Certificate:       d.Get("certificate").(string),
+		PrivateKey:        d.Get("private_key").(string),
+		Token:             d.Get("token").(string),
+		SessionID:         d.Get("session_id").(string),
+	}
+
+	logging.Debug(context.Background(), "__custom__ : vsphere config", logging.Fields{"config": c})
+
+	return c, c.Validate()
+}
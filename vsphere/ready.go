@@ -0,0 +1,119 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/vmware/govmomi/guest"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type GuestReadyStage string
+
+const (
+	GuestReadyTools           = GuestReadyStage("toolsRunning")
+	GuestReadyGuestOperations = GuestReadyStage("guestOperationsReady")
+	GuestReadyIP              = GuestReadyStage("ipAddress")
+	GuestReadyCredentials     = GuestReadyStage("credentials")
+)
+
+// ReadyOptions controls WaitForGuestReady.
+type ReadyOptions struct {
+	// Timeout for the whole wait. Defaults to 10 minutes.
+	Timeout time.Duration
+	// IgnoreIP skips waiting for guest.ipAddress, for guests without networking.
+	IgnoreIP bool
+	// Progress is called once for every stage that has been reached, in order. A stage is only reached
+	// once the stages before it are, so guest operations reported ready before VMware Tools wait for them.
+	Progress func(stage GuestReadyStage)
+}
+
+// WaitForGuestReady waits until VMware Tools are running, guest operations are ready, the guest reports an
+// IP address and auth is accepted by the guest, which is what InvokeCommands and friends need after power-on or clone.
+func WaitForGuestReady(ctx context.Context, vm *object.VirtualMachine, auth types.BaseGuestAuthentication, opts ReadyOptions) error {
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Minute * 10
+	}
+
+	ctx = logging.WithFields(ctx, logging.Fields{"vm": vm.Reference().Value})
+
+	progress := func(stage GuestReadyStage) {
+		logging.Debug(ctx, "guest ready stage reached", logging.Fields{"stage": stage})
+		if opts.Progress != nil {
+			opts.Progress(stage)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	pc := property.DefaultCollector(vm.Client())
+
+	running, ready, ip := false, false, opts.IgnoreIP
+	reported := map[GuestReadyStage]bool{}
+
+	err := property.Wait(ctx, pc, vm.Reference(), []string{"guest.toolsRunningStatus", "guest.guestOperationsReady", "guest.ipAddress"}, func(pcs []types.PropertyChange) bool {
+		for _, c := range pcs {
+			switch c.Name {
+			case "guest.toolsRunningStatus":
+				running = c.Val == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+			case "guest.guestOperationsReady":
+				ready, _ = c.Val.(bool)
+			case "guest.ipAddress":
+				if !opts.IgnoreIP {
+					s, _ := c.Val.(string)
+					ip = s != ""
+				}
+			}
+		}
+
+		for _, s := range []struct {
+			stage GuestReadyStage
+			ok    bool
+		}{{GuestReadyTools, running}, {GuestReadyGuestOperations, ready}, {GuestReadyIP, ip}} {
+			// a stage only counts once every stage before it has been reached
+			if !s.ok {
+				break
+			}
+			if s.stage == GuestReadyIP && opts.IgnoreIP {
+				continue
+			}
+			if !reported[s.stage] {
+				reported[s.stage] = true
+				progress(s.stage)
+			}
+		}
+
+		return running && ready && ip
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for guest: %s", err)
+	}
+
+	opsmgr := guest.NewOperationsManager(vm.Client(), vm.Reference())
+
+	for {
+		err = TestCredentials(ctx, auth, opsmgr)
+		if err == nil {
+			progress(GuestReadyCredentials)
+			return nil
+		}
+
+		if soap.IsSoapFault(err) {
+			if _, ok := soap.ToSoapFault(err).VimFault().(types.InvalidGuestLogin); ok {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for guest credentials: %s", err)
+		case <-time.After(time.Second * 5):
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// guestAuthManager accepts any credentials, the simulator has no GuestAuthManager of its own.
+type guestAuthManager struct {
+	mo.GuestAuthManager
+}
+
+func (m *guestAuthManager) ValidateCredentialsInGuest(req *types.ValidateCredentialsInGuest) soap.HasFault {
+	return &methods.ValidateCredentialsInGuestBody{Res: new(types.ValidateCredentialsInGuestResponse)}
+}
+
+func TestWaitForGuestReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ReadyOptions
+		changes []types.PropertyChange
+		stages  [][]GuestReadyStage
+	}{
+		{
+			name: "stages in turn",
+			changes: []types.PropertyChange{
+				{Name: "guest.toolsRunningStatus", Val: string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)},
+				{Name: "guest.guestOperationsReady", Val: true},
+				{Name: "guest.ipAddress", Val: "10.0.0.10"},
+			},
+			stages: [][]GuestReadyStage{
+				{GuestReadyTools},
+				{GuestReadyGuestOperations},
+				{GuestReadyIP, GuestReadyCredentials},
+			},
+		},
+		{
+			name: "guest operations before tools",
+			changes: []types.PropertyChange{
+				{Name: "guest.guestOperationsReady", Val: true},
+				{Name: "guest.toolsRunningStatus", Val: string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)},
+				{Name: "guest.ipAddress", Val: "10.0.0.10"},
+			},
+			stages: [][]GuestReadyStage{
+				nil,
+				{GuestReadyTools, GuestReadyGuestOperations},
+				{GuestReadyIP, GuestReadyCredentials},
+			},
+		},
+		{
+			name: "ignore ip",
+			opts: ReadyOptions{IgnoreIP: true},
+			changes: []types.PropertyChange{
+				{Name: "guest.toolsRunningStatus", Val: string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)},
+				{Name: "guest.guestOperationsReady", Val: true},
+			},
+			stages: [][]GuestReadyStage{
+				{GuestReadyTools},
+				{GuestReadyGuestOperations, GuestReadyCredentials},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, c *vim25.Client) {
+				ref := types.ManagedObjectReference{Type: "GuestAuthManager", Value: "guestOperationsAuthManager"}
+				auth := new(guestAuthManager)
+				auth.Self = ref
+				simulator.Map.Put(auth)
+				simulator.Map.Get(*c.ServiceContent.GuestOperationsManager).(*simulator.GuestOperationsManager).AuthManager = &ref
+
+				vm, err := find.NewFinder(c).VirtualMachine(ctx, "DC0_H0_VM0")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				obj := simulator.Map.Get(vm.Reference()).(*simulator.VirtualMachine)
+				update := func(changes ...types.PropertyChange) {
+					simulator.Map.WithLock(obj, func() {
+						simulator.Map.Update(obj, changes)
+					})
+				}
+
+				update(
+					types.PropertyChange{Name: "guest.toolsRunningStatus", Val: string(types.VirtualMachineToolsRunningStatusGuestToolsNotRunning)},
+					types.PropertyChange{Name: "guest.guestOperationsReady", Val: false},
+					types.PropertyChange{Name: "guest.ipAddress", Val: ""},
+				)
+
+				stages := make(chan GuestReadyStage, 10)
+				opts := tt.opts
+				opts.Timeout = time.Second * 10
+				opts.Progress = func(stage GuestReadyStage) { stages <- stage }
+
+				wctx, cancel := context.WithCancel(ctx)
+				done := make(chan error, 1)
+				// stop waiting before the simulator goes away, also when the test fails early
+				defer func() {
+					cancel()
+					<-done
+				}()
+
+				go func() {
+					done <- WaitForGuestReady(wctx, vm, &types.NamePasswordAuthentication{Username: "user", Password: "pass"}, opts)
+				}()
+
+				for i, change := range tt.changes {
+					update(change)
+
+					var got []GuestReadyStage
+					for range tt.stages[i] {
+						select {
+						case s := <-stages:
+							got = append(got, s)
+						case <-time.After(time.Second * 5):
+						}
+					}
+
+					// anything reported beyond what we expect shows up here
+					select {
+					case s := <-stages:
+						got = append(got, s)
+					case <-time.After(time.Millisecond * 200):
+					}
+
+					if !reflect.DeepEqual(got, tt.stages[i]) {
+						t.Fatalf("after %s: stages %v, want %v", change.Name, got, tt.stages[i])
+					}
+				}
+
+				select {
+				case err := <-done:
+					done <- err
+					if err != nil {
+						t.Errorf("WaitForGuestReady: %s", err)
+					}
+				case <-time.After(time.Second * 5):
+					t.Error("WaitForGuestReady did not return")
+				}
+			})
+		})
+	}
+}
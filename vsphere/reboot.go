@@ -0,0 +1,230 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/roshankarande/utils/vsphere/guest/toolbox"
+	"github.com/vmware/govmomi/guest"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// ExitCodeRebootRequired is ERROR_SUCCESS_REBOOT_REQUIRED, returned by msiexec and most installers.
+	ExitCodeRebootRequired = 3010
+	// ExitCodeRebootInitiated is ERROR_SUCCESS_REBOOT_INITIATED, the guest is already restarting.
+	ExitCodeRebootInitiated = 1641
+
+	// DefaultRebootMarker can be written by a step to request a reboot before the next step.
+	DefaultRebootMarker = "##vsphere[reboot]"
+)
+
+// RebootOptions controls how RunWithReboot detects and waits for reboots between steps.
+type RebootOptions struct {
+	// Marker in the output of a step that requests a reboot. Defaults to DefaultRebootMarker.
+	Marker string
+	// Timeout for the guest to come back after a reboot. Defaults to 20 minutes.
+	Timeout time.Duration
+	// MaxReboots guards against steps that keep asking for a reboot. Defaults to 5.
+	MaxReboots int
+}
+
+// rebootGuest is the guest RunWithReboot runs its steps on and reboots.
+type rebootGuest interface {
+	// runScript runs script, sending its output to out and closing out when done.
+	runScript(ctx context.Context, out chan string, script string) error
+	// operationsReady reports whether guest operations are available.
+	operationsReady(ctx context.Context) (bool, error)
+	reboot(ctx context.Context) error
+	// waitRebooted waits for the guest to go down and for guest operations to be ready again.
+	waitRebooted(ctx context.Context, timeout time.Duration) error
+}
+
+// vmGuest is the rebootGuest of a VM, running scripts through VMware Tools.
+type vmGuest struct {
+	vm     *object.VirtualMachine
+	auth   types.BaseGuestAuthentication
+	client *toolbox.Client
+}
+
+func (g *vmGuest) runScript(ctx context.Context, out chan string, script string) error {
+	return g.client.RunScript(ctx, out, script)
+}
+
+func (g *vmGuest) operationsReady(ctx context.Context) (bool, error) {
+	return guestOperationsReady(ctx, g.vm)
+}
+
+func (g *vmGuest) reboot(ctx context.Context) error {
+	return g.vm.RebootGuest(ctx)
+}
+
+func (g *vmGuest) waitRebooted(ctx context.Context, timeout time.Duration) error {
+	if err := waitForReboot(ctx, g.vm, timeout); err != nil {
+		return err
+	}
+
+	return WaitForGuestReady(ctx, g.vm, g.auth, ReadyOptions{Timeout: timeout, IgnoreIP: true})
+}
+
+// RunWithReboot runs steps as scripts on the guest one after another. When a step exits with
+// 3010 or 1641, prints the reboot marker, or the guest reboots underneath it, the VM is rebooted
+// through vSphere if needed, and the next step starts once guest operations are ready again. This holds for
+// the last step too: RunWithReboot only returns once a guest it rebooted is ready again.
+func RunWithReboot(ctx context.Context, vm *object.VirtualMachine, auth types.BaseGuestAuthentication, data chan string, steps []string, opts RebootOptions) error {
+	ctx = logging.WithFields(ctx, logging.Fields{"vm": vm.Reference().Value})
+
+	// the process and file managers are vCenter objects, so the client outlives guest reboots
+	c, err := newToolboxClient(ctx, auth, guest.NewOperationsManager(vm.Client(), vm.Reference()))
+	if err != nil {
+		close(data)
+		return err
+	}
+
+	return runWithReboot(ctx, &vmGuest{vm: vm, auth: auth, client: c}, data, steps, opts)
+}
+
+func runWithReboot(ctx context.Context, g rebootGuest, data chan string, steps []string, opts RebootOptions) error {
+	defer close(data)
+
+	if opts.Marker == "" {
+		opts.Marker = DefaultRebootMarker
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Minute * 20
+	}
+
+	if opts.MaxReboots == 0 {
+		opts.MaxReboots = 5
+	}
+
+	reboots := 0
+
+	for i, step := range steps {
+		out := make(chan string)
+		marked := make(chan bool, 1)
+
+		go func() {
+			seen := false
+			tail := ""
+			for s := range out {
+				data <- s
+				if !seen {
+					seen = strings.Contains(tail+s, opts.Marker)
+					tail = lastN(tail+s, len(opts.Marker))
+				}
+			}
+			marked <- seen
+		}()
+
+		runErr := g.runScript(ctx, out, step)
+		reboot := <-marked
+		initiated := false
+
+		if runErr != nil {
+			var exit *toolbox.ExitError
+			if errors.As(runErr, &exit) {
+				reboot, initiated = rebootExitCode(exit.Code)
+				if !reboot {
+					return fmt.Errorf("step %d: %s", i, runErr)
+				}
+			} else {
+				// the poll loop loses the process when the guest restarts underneath it
+				ready, err := g.operationsReady(ctx)
+				if err != nil || ready {
+					return fmt.Errorf("step %d: %s", i, runErr)
+				}
+				reboot, initiated = true, true
+			}
+		}
+
+		// a reboot requested by the last step is still done, so the guest is up again when we return
+		if !reboot {
+			continue
+		}
+
+		reboots++
+		if reboots > opts.MaxReboots {
+			return fmt.Errorf("step %d: more than %d reboots requested", i, opts.MaxReboots)
+		}
+
+		logging.Info(ctx, "rebooting guest", logging.Fields{"step": i, "initiated_by_guest": initiated})
+
+		if !initiated {
+			if err := g.reboot(ctx); err != nil {
+				return fmt.Errorf("step %d: reboot: %s", i, err)
+			}
+		}
+
+		if err := g.waitRebooted(ctx, opts.Timeout); err != nil {
+			return fmt.Errorf("step %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+func guestOperationsReady(ctx context.Context, vm *object.VirtualMachine) (bool, error) {
+	var o mo.VirtualMachine
+
+	err := vm.Properties(ctx, vm.Reference(), []string{"guest.toolsRunningStatus", "guest.guestOperationsReady"}, &o)
+	if err != nil {
+		return false, err
+	}
+
+	if o.Guest == nil || o.Guest.GuestOperationsReady == nil {
+		return false, nil
+	}
+
+	return o.Guest.ToolsRunningStatus == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning) && *o.Guest.GuestOperationsReady, nil
+}
+
+// rebootExitCode reports whether a step exit code asks for a reboot, and whether the guest already started it.
+func rebootExitCode(code int) (reboot, initiated bool) {
+	switch code {
+	case ExitCodeRebootRequired:
+		return true, false
+	case ExitCodeRebootInitiated:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func lastN(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[len(s)-n:]
+}
+
+// waitForReboot waits for VMware Tools to go away while the guest restarts.
+func waitForReboot(ctx context.Context, vm *object.VirtualMachine, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pc := property.DefaultCollector(vm.Client())
+
+	err := property.Wait(ctx, pc, vm.Reference(), []string{"guest.toolsRunningStatus"}, func(pcs []types.PropertyChange) bool {
+		for _, c := range pcs {
+			if s, ok := c.Val.(string); ok && s != string(types.VirtualMachineToolsRunningStatusGuestToolsRunning) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for reboot: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roshankarande/utils/vsphere/guest/toolbox"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// fakeGuest runs steps by returning the result and output configured for them.
+type fakeGuest struct {
+	results map[string]error
+	output  map[string]string
+	ready   bool
+
+	ran     []string
+	reboots int
+	waits   int
+}
+
+func (g *fakeGuest) runScript(ctx context.Context, out chan string, script string) error {
+	defer close(out)
+
+	g.ran = append(g.ran, script)
+	if s, ok := g.output[script]; ok {
+		out <- s
+	}
+
+	return g.results[script]
+}
+
+func (g *fakeGuest) operationsReady(ctx context.Context) (bool, error) {
+	return g.ready, nil
+}
+
+func (g *fakeGuest) reboot(ctx context.Context) error {
+	g.reboots++
+	return nil
+}
+
+func (g *fakeGuest) waitRebooted(ctx context.Context, timeout time.Duration) error {
+	g.waits++
+	return nil
+}
+
+func exitCode(code int) error {
+	// wrapped the way callers of the toolbox client usually see it
+	return fmt.Errorf("run: %w", &toolbox.ExitError{Err: fmt.Errorf("exit code %d", code), Code: code})
+}
+
+func TestRunWithReboot(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []string
+		results map[string]error
+		output  map[string]string
+		ready   bool
+		opts    RebootOptions
+		err     bool
+		ran     []string
+		reboots int
+		waits   int
+	}{
+		{
+			name:  "no reboot",
+			steps: []string{"a", "b"},
+			ran:   []string{"a", "b"},
+		},
+		{
+			name:    "reboot required",
+			steps:   []string{"a", "b"},
+			results: map[string]error{"a": exitCode(ExitCodeRebootRequired)},
+			ran:     []string{"a", "b"},
+			reboots: 1,
+			waits:   1,
+		},
+		{
+			name:    "reboot initiated",
+			steps:   []string{"a", "b"},
+			results: map[string]error{"a": exitCode(ExitCodeRebootInitiated)},
+			ran:     []string{"a", "b"},
+			waits:   1,
+		},
+		{
+			name:    "failing step",
+			steps:   []string{"a", "b"},
+			results: map[string]error{"a": exitCode(1)},
+			err:     true,
+			ran:     []string{"a"},
+		},
+		{
+			name:    "reboot required by the last step",
+			steps:   []string{"a"},
+			results: map[string]error{"a": exitCode(ExitCodeRebootRequired)},
+			ran:     []string{"a"},
+			reboots: 1,
+			waits:   1,
+		},
+		{
+			name:    "marker",
+			steps:   []string{"a", "b"},
+			output:  map[string]string{"a": "done\n" + DefaultRebootMarker + "\n"},
+			ran:     []string{"a", "b"},
+			reboots: 1,
+			waits:   1,
+		},
+		{
+			name:    "guest restarted underneath the step",
+			steps:   []string{"a", "b"},
+			results: map[string]error{"a": errors.New("process lost")},
+			ran:     []string{"a", "b"},
+			waits:   1,
+		},
+		{
+			name:    "step failed while guest operations are ready",
+			steps:   []string{"a", "b"},
+			results: map[string]error{"a": errors.New("process lost")},
+			ready:   true,
+			err:     true,
+			ran:     []string{"a"},
+		},
+		{
+			name:    "too many reboots",
+			steps:   []string{"a", "b", "c"},
+			results: map[string]error{"a": exitCode(ExitCodeRebootRequired), "b": exitCode(ExitCodeRebootRequired)},
+			opts:    RebootOptions{MaxReboots: 1},
+			err:     true,
+			ran:     []string{"a", "b"},
+			reboots: 1,
+			waits:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &fakeGuest{results: tt.results, output: tt.output, ready: tt.ready}
+
+			data := make(chan string)
+			done := make(chan string)
+			go func() {
+				all := ""
+				for s := range data {
+					all += s
+				}
+				done <- all
+			}()
+
+			err := runWithReboot(context.Background(), g, data, tt.steps, tt.opts)
+			out := <-done
+
+			if (err != nil) != tt.err {
+				t.Errorf("err = %v, want error %t", err, tt.err)
+			}
+
+			if !reflect.DeepEqual(g.ran, tt.ran) {
+				t.Errorf("ran %v, want %v", g.ran, tt.ran)
+			}
+
+			if g.reboots != tt.reboots || g.waits != tt.waits {
+				t.Errorf("%d reboots and %d waits, want %d and %d", g.reboots, g.waits, tt.reboots, tt.waits)
+			}
+
+			if want := tt.output["a"]; out != want {
+				t.Errorf("output %q, want %q", out, want)
+			}
+		})
+	}
+}
+
+func TestWaitForReboot(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		vm, err := find.NewFinder(c).VirtualMachine(ctx, "DC0_H0_VM0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		obj := simulator.Map.Get(vm.Reference()).(*simulator.VirtualMachine)
+		setTools := func(status types.VirtualMachineToolsRunningStatus) {
+			simulator.Map.WithLock(obj, func() {
+				simulator.Map.Update(obj, []types.PropertyChange{{Name: "guest.toolsRunningStatus", Val: string(status)}})
+			})
+		}
+
+		setTools(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+
+		if err = waitForReboot(ctx, vm, time.Millisecond*200); err == nil {
+			t.Error("waitForReboot returned while tools kept running")
+		}
+
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			setTools(types.VirtualMachineToolsRunningStatusGuestToolsNotRunning)
+		}()
+
+		if err = waitForReboot(ctx, vm, time.Second*5); err != nil {
+			t.Errorf("waitForReboot: %s", err)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"gopkg.in/yaml.v2"
+)
+
+// VMReportVersion is the schema version of VMReport. It is bumped whenever a field is renamed, removed or
+// changes meaning; new fields are added without a bump.
+const VMReportVersion = "1"
+
+// Report formats.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// VMReport is a flat, stable view of a VM for reporting, built from a VMInfo. Unlike VMInfo it only holds
+// plain values, so its JSON and YAML forms do not change with the govmomi version.
+type VMReport struct {
+	Version string `json:"version" yaml:"version"`
+
+	Name         string `json:"name" yaml:"name"`
+	MoRef        string `json:"moref" yaml:"moref"`
+	UUID         string `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	InstanceUUID string `json:"instance_uuid,omitempty" yaml:"instance_uuid,omitempty"`
+	Annotation   string `json:"annotation,omitempty" yaml:"annotation,omitempty"`
+	Template     bool   `json:"template,omitempty" yaml:"template,omitempty"`
+	PowerState   string `json:"power_state" yaml:"power_state"`
+
+	GuestID         string `json:"guest_id,omitempty" yaml:"guest_id,omitempty"`
+	GuestFullName   string `json:"guest_full_name,omitempty" yaml:"guest_full_name,omitempty"`
+	Hostname        string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	IPAddress       string `json:"ip_address,omitempty" yaml:"ip_address,omitempty"`
+	HardwareVersion string `json:"hardware_version,omitempty" yaml:"hardware_version,omitempty"`
+
+	NumCPU         int32 `json:"num_cpu" yaml:"num_cpu"`
+	CoresPerSocket int32 `json:"cores_per_socket,omitempty" yaml:"cores_per_socket,omitempty"`
+	MemoryMB       int32 `json:"memory_mb" yaml:"memory_mb"`
+
+	// StorageCommittedBytes is the space used on datastores, StorageUncommittedBytes what thin disks may grow by.
+	StorageCommittedBytes   int64 `json:"storage_committed_bytes" yaml:"storage_committed_bytes"`
+	StorageUncommittedBytes int64 `json:"storage_uncommitted_bytes" yaml:"storage_uncommitted_bytes"`
+
+	Tools ToolsReport `json:"tools" yaml:"tools"`
+
+	Disks      []DiskReport `json:"disks" yaml:"disks"`
+	NICs       []NICReport  `json:"nics" yaml:"nics"`
+	Datastores []string     `json:"datastores" yaml:"datastores"`
+	Host       string       `json:"host,omitempty" yaml:"host,omitempty"`
+	Cluster    string       `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	// Tags are "category/name"; only set by GetVMReport with WithReportTags.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+type ToolsReport struct {
+	// Status is the toolsVersionStatus2, e.g. guestToolsCurrent or guestToolsNotInstalled.
+	Status        string `json:"status,omitempty" yaml:"status,omitempty"`
+	RunningStatus string `json:"running_status,omitempty" yaml:"running_status,omitempty"`
+	Version       string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+type DiskReport struct {
+	Label         string `json:"label" yaml:"label"`
+	CapacityBytes int64  `json:"capacity_bytes" yaml:"capacity_bytes"`
+	BackingType   string `json:"backing_type" yaml:"backing_type"`
+	FileName      string `json:"file_name,omitempty" yaml:"file_name,omitempty"`
+	Datastore     string `json:"datastore,omitempty" yaml:"datastore,omitempty"`
+	DiskMode      string `json:"disk_mode,omitempty" yaml:"disk_mode,omitempty"`
+	Thin          *bool  `json:"thin,omitempty" yaml:"thin,omitempty"`
+}
+
+type NICReport struct {
+	Label       string   `json:"label" yaml:"label"`
+	AdapterType string   `json:"adapter_type" yaml:"adapter_type"`
+	MacAddress  string   `json:"mac_address,omitempty" yaml:"mac_address,omitempty"`
+	Connected   bool     `json:"connected" yaml:"connected"`
+	Portgroup   string   `json:"portgroup,omitempty" yaml:"portgroup,omitempty"`
+	IPAddresses []string `json:"ip_addresses,omitempty" yaml:"ip_addresses,omitempty"`
+}
+
+// NewVMReport flattens info into a VMReport. Cluster and tags are not part of a VMInfo and are left empty;
+// GetVMReport fills them in.
+func NewVMReport(info *VMInfo) *VMReport {
+	vm := info.VirtualMachine
+
+	r := VMReport{
+		Version:    VMReportVersion,
+		MoRef:      vm.Reference().String(),
+		Name:       vm.Name,
+		PowerState: string(vm.Runtime.PowerState),
+		Host:       info.HostSystem.Name,
+		Disks:      []DiskReport{},
+		NICs:       []NICReport{},
+		Datastores: []string{},
+	}
+
+	if c := vm.Summary.Config; c.Name != "" {
+		r.UUID, r.InstanceUUID, r.Annotation, r.Template = c.Uuid, c.InstanceUuid, c.Annotation, c.Template
+		r.GuestID, r.GuestFullName = c.GuestId, c.GuestFullName
+		r.NumCPU, r.MemoryMB = c.NumCpu, c.MemorySizeMB
+	}
+
+	if c := vm.Config; c != nil {
+		r.HardwareVersion = c.Version
+		r.CoresPerSocket = c.Hardware.NumCoresPerSocket
+	}
+
+	if s := vm.Summary.Storage; s != nil {
+		r.StorageCommittedBytes, r.StorageUncommittedBytes = s.Committed, s.Uncommitted
+	}
+
+	ips := map[int32][]string{}
+
+	if g := vm.Guest; g != nil {
+		r.Hostname, r.IPAddress = g.HostName, g.IpAddress
+		r.Tools = ToolsReport{Status: g.ToolsVersionStatus2, RunningStatus: g.ToolsRunningStatus, Version: g.ToolsVersion}
+
+		if g.GuestFullName != "" {
+			r.GuestID, r.GuestFullName = g.GuestId, g.GuestFullName
+		}
+
+		for _, n := range g.Net {
+			ips[n.DeviceConfigId] = append(ips[n.DeviceConfigId], n.IpAddress...)
+		}
+	}
+
+	datastores := map[types.ManagedObjectReference]string{}
+	for _, ds := range info.Datastores {
+		datastores[ds.Reference()] = ds.Name
+		r.Datastores = append(r.Datastores, ds.Name)
+	}
+	sort.Strings(r.Datastores)
+
+	// distributed port groups are keyed by their MoRef value
+	portgroups := map[string]string{}
+	for _, n := range info.Networks {
+		portgroups[n.Reference().Value] = n.Name
+	}
+
+	if info.Devices != nil {
+		for _, d := range info.Devices.Disks {
+			disk := DiskReport{
+				Label:         d.Label,
+				CapacityBytes: d.CapacityBytes,
+				BackingType:   d.BackingType,
+				FileName:      d.FileName,
+				DiskMode:      d.DiskMode,
+				Thin:          d.ThinProvisioned,
+			}
+			if d.Datastore != nil {
+				disk.Datastore = datastores[*d.Datastore]
+			}
+			r.Disks = append(r.Disks, disk)
+		}
+
+		for _, n := range info.Devices.NICs {
+			nic := NICReport{
+				Label:       n.Label,
+				AdapterType: n.AdapterType,
+				MacAddress:  n.MacAddress,
+				Connected:   n.Connected != nil && *n.Connected,
+				Portgroup:   n.NetworkName,
+				IPAddresses: ips[n.Key],
+			}
+			if n.PortgroupKey != "" {
+				nic.Portgroup = portgroups[n.PortgroupKey]
+			}
+			r.NICs = append(r.NICs, nic)
+		}
+	}
+
+	return &r
+}
+
+type reportOptions struct {
+	tags *tags.Manager
+}
+
+// ReportOption configures GetVMReport.
+type ReportOption func(*reportOptions)
+
+// WithReportTags adds the tags attached to the VM to the report, looked up with m.
+func WithReportTags(m *tags.Manager) ReportOption {
+	return func(o *reportOptions) {
+		o.tags = m
+	}
+}
+
+// GetVMReport returns the VMReport of the VM found by FindVM for name, including its cluster and, with
+// WithReportTags, its tags. Like GetVM it returns a partial report with a VMInfoError if some parts could
+// not be retrieved.
+func GetVMReport(ctx context.Context, c *vim25.Client, name string, opts ...ReportOption) (*VMReport, error) {
+	var o reportOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	info, err := GetVM(ctx, c, name)
+	if info == nil {
+		return nil, err
+	}
+
+	var errs VMInfoError
+	if e, ok := err.(VMInfoError); ok {
+		errs = e
+	}
+
+	r := NewVMReport(info)
+
+	if parent := info.HostSystem.Parent; parent != nil && parent.Type == "ClusterComputeResource" {
+		var cluster mo.ClusterComputeResource
+		if err = property.DefaultCollector(c).RetrieveOne(ctx, *parent, []string{"name"}, &cluster); err != nil {
+			errs = append(errs, fmt.Errorf("cluster: %s", err))
+		}
+		r.Cluster = cluster.Name
+	}
+
+	if o.tags != nil {
+		if r.Tags, err = tagNames(ctx, o.tags, info.VirtualMachine.Reference()); err != nil {
+			errs = append(errs, fmt.Errorf("tags: %s", err))
+		}
+	}
+
+	if len(errs) != 0 {
+		return r, errs
+	}
+
+	return r, nil
+}
+
+// tagNames returns the tags attached to ref as sorted "category/name" strings.
+func tagNames(ctx context.Context, m *tags.Manager, ref types.ManagedObjectReference) ([]string, error) {
+	attached, err := m.GetAttachedTags(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := map[string]string{}
+	var names []string
+
+	for _, tag := range attached {
+		category, ok := categories[tag.CategoryID]
+		if !ok {
+			cat, err := m.GetCategory(ctx, tag.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			category = cat.Name
+			categories[tag.CategoryID] = category
+		}
+		names = append(names, category+"/"+tag.Name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// VMReports is the document MarshalVMReports writes, a list of reports under the schema version.
+type VMReports struct {
+	Version string      `json:"version" yaml:"version"`
+	VMs     []*VMReport `json:"vms" yaml:"vms"`
+}
+
+// MarshalVMReports encodes reports as a VMReports document in format, FormatJSON or FormatYAML.
+func MarshalVMReports(format string, reports ...*VMReport) ([]byte, error) {
+	doc := VMReports{Version: VMReportVersion, VMs: reports}
+	if doc.VMs == nil {
+		doc.VMs = []*VMReport{}
+	}
+
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(doc, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(doc)
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+}
@@ -0,0 +1,141 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"gopkg.in/yaml.v2"
+)
+
+func testVMInfo() *VMInfo {
+	ds := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}
+	connected, thin := true, true
+
+	var info VMInfo
+
+	vm := &info.VirtualMachine
+	vm.Self = types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"}
+	vm.Name = "web1"
+	vm.Runtime.PowerState = types.VirtualMachinePowerStatePoweredOn
+	vm.Summary.Config = types.VirtualMachineConfigSummary{
+		Name: "web1", Uuid: "4201", InstanceUuid: "5001", GuestId: "otherGuest", GuestFullName: "Other",
+		NumCpu: 4, MemorySizeMB: 8192, Annotation: "=owner",
+	}
+	vm.Summary.Storage = &types.VirtualMachineStorageSummary{Committed: 10 << 30, Uncommitted: 5 << 30}
+	vm.Config = &types.VirtualMachineConfigInfo{Version: "vmx-14", Hardware: types.VirtualHardware{NumCoresPerSocket: 2}}
+	vm.Guest = &types.GuestInfo{
+		HostName: "web1.example.com", IpAddress: "10.0.0.5", GuestId: "ubuntu64Guest", GuestFullName: "Ubuntu Linux (64-bit)",
+		ToolsVersionStatus2: "guestToolsCurrent", ToolsRunningStatus: "guestToolsRunning", ToolsVersion: "11269",
+		Net: []types.GuestNicInfo{{DeviceConfigId: 4000, IpAddress: []string{"10.0.0.5", "fe80::1"}}},
+	}
+
+	var store mo.Datastore
+	store.Self, store.Name = ds, "ds1"
+	var pg mo.Network
+	pg.Self, pg.Name = types.ManagedObjectReference{Type: "DistributedVirtualPortgroup", Value: "dvportgroup-7"}, "prod-pg"
+	info.Datastores, info.Networks = []mo.Datastore{store}, []mo.Network{pg}
+	info.HostSystem.Name = "esx1"
+
+	info.Devices = &DeviceInventory{
+		Disks: []Disk{{Device: Device{Key: 2000, Label: "Hard disk 1"}, CapacityBytes: 40 << 30, BackingType: DiskBackingFlat,
+			FileName: "[ds1] web1/web1.vmdk", Datastore: &ds, DiskMode: "persistent", ThinProvisioned: &thin}},
+		NICs: []NIC{
+			{Device: Device{Key: 4000, Label: "Network adapter 1", Connected: &connected}, AdapterType: "vmxnet3",
+				MacAddress: "00:50:56:00:00:01", PortgroupKey: "dvportgroup-7"},
+			{Device: Device{Key: 4001, Label: "Network adapter 2"}, AdapterType: "e1000", NetworkName: "VM Network"},
+		},
+	}
+
+	return &info
+}
+
+func TestNewVMReport(t *testing.T) {
+	thin := true
+
+	want := &VMReport{
+		Version: VMReportVersion, Name: "web1", MoRef: "VirtualMachine:vm-42", UUID: "4201", InstanceUUID: "5001",
+		Annotation: "=owner", PowerState: "poweredOn",
+		GuestID: "ubuntu64Guest", GuestFullName: "Ubuntu Linux (64-bit)", Hostname: "web1.example.com", IPAddress: "10.0.0.5",
+		HardwareVersion: "vmx-14", NumCPU: 4, CoresPerSocket: 2, MemoryMB: 8192,
+		StorageCommittedBytes: 10 << 30, StorageUncommittedBytes: 5 << 30,
+		Tools: ToolsReport{Status: "guestToolsCurrent", RunningStatus: "guestToolsRunning", Version: "11269"},
+		Disks: []DiskReport{{Label: "Hard disk 1", CapacityBytes: 40 << 30, BackingType: DiskBackingFlat,
+			FileName: "[ds1] web1/web1.vmdk", Datastore: "ds1", DiskMode: "persistent", Thin: &thin}},
+		NICs: []NICReport{
+			{Label: "Network adapter 1", AdapterType: "vmxnet3", MacAddress: "00:50:56:00:00:01", Connected: true,
+				Portgroup: "prod-pg", IPAddresses: []string{"10.0.0.5", "fe80::1"}},
+			{Label: "Network adapter 2", AdapterType: "e1000", Portgroup: "VM Network"},
+		},
+		Datastores: []string{"ds1"},
+		Host:       "esx1",
+	}
+
+	if got := NewVMReport(testVMInfo()); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewVMReport =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// a VM whose properties were not retrieved still gets empty lists
+	var empty VMInfo
+	empty.VirtualMachine.Self = types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}
+	r := NewVMReport(&empty)
+	if r.Disks == nil || r.NICs == nil || r.Datastores == nil || r.Version != VMReportVersion {
+		t.Errorf("NewVMReport of an empty VMInfo = %+v", r)
+	}
+}
+
+func TestMarshalVMReports(t *testing.T) {
+	reports := []*VMReport{NewVMReport(testVMInfo()), NewVMReport(testVMInfo())}
+	reports[1].Name, reports[1].Tags = "web2", []string{"env/prod"}
+
+	unmarshal := map[string]func([]byte, interface{}) error{FormatJSON: json.Unmarshal, FormatYAML: yaml.Unmarshal}
+
+	for format, decode := range unmarshal {
+		b, err := MarshalVMReports(format, reports...)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		var doc VMReports
+		if err = decode(b, &doc); err != nil {
+			t.Fatalf("%s: %s\n%s", format, err, b)
+		}
+
+		want := VMReports{Version: VMReportVersion, VMs: reports}
+		if !reflect.DeepEqual(doc, want) {
+			t.Errorf("%s round trip =\n%+v\nwant\n%+v", format, doc, want)
+		}
+
+		b, err = MarshalVMReports(format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		doc = VMReports{}
+		if err = decode(b, &doc); err != nil || doc.VMs == nil || len(doc.VMs) != 0 {
+			t.Errorf("%s: no reports encode as %s", format, b)
+		}
+	}
+
+	if _, err := MarshalVMReports("xml", reports...); err == nil {
+		t.Error("MarshalVMReports accepted an unknown format")
+	}
+}
+
+func TestGetVMReport(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		r, err := GetVMReport(ctx, c, "DC0_C0_RP0_VM0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Name != "DC0_C0_RP0_VM0" || r.Cluster != "DC0_C0" || r.Host == "" || len(r.Disks) == 0 || len(r.NICs) == 0 {
+			t.Errorf("report = %+v", r)
+		}
+	})
+}
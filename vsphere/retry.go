@@ -0,0 +1,163 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// RetryPolicy controls how NewClient retries failed SOAP calls.
+type RetryPolicy struct {
+	// Attempts is the total number of tries, 1 disables retries.
+	Attempts int
+	// InitialBackoff is the delay before the first retry, doubled on each further retry up to MaxBackoff.
+	// A random jitter of up to 20% is added.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Idempotent reports whether the method, e.g. "RetrievePropertiesEx", is safe to send again after a network
+	// error, where it is unknown whether vCenter executed it. Defaults to IsIdempotent.
+	Idempotent func(method string) bool
+}
+
+// DefaultRetryPolicy is used by NewClient unless WithRetry is given.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:       4,
+	InitialBackoff: time.Millisecond * 500,
+	MaxBackoff:     time.Second * 15,
+}
+
+// WithRetry replaces DefaultRetryPolicy.
+func WithRetry(p RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retry = &p
+	}
+}
+
+var idempotentPrefixes = []string{"Retrieve", "Find", "Query", "List", "Get", "Validate", "Browse", "Fetch", "Has"}
+
+var idempotentMethods = map[string]bool{
+	"CurrentTime":                   true,
+	"WaitForUpdatesEx":              true,
+	"InitiateFileTransferFromGuest": true,
+}
+
+// IsIdempotent reports whether method only reads state.
+func IsIdempotent(method string) bool {
+	if idempotentMethods[method] {
+		return true
+	}
+
+	for _, p := range idempotentPrefixes {
+		if strings.HasPrefix(method, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRejected reports whether err is a fault vCenter raises before doing anything,
+// so any method can be retried after it.
+func isRejected(err error) bool {
+	if !soap.IsSoapFault(err) {
+		return false
+	}
+
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.TaskInProgress, *types.TaskInProgress,
+		types.ConcurrentAccess, *types.ConcurrentAccess,
+		types.GuestOperationsUnavailable, *types.GuestOperationsUnavailable:
+		return true
+	}
+
+	return false
+}
+
+// isTransient reports whether err is a network error or a fault that is likely to go away.
+func isTransient(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	if t, ok := err.(interface{ Temporary() bool }); ok && t.Temporary() {
+		return true
+	}
+
+	if t, ok := err.(interface{ Timeout() bool }); ok && t.Timeout() {
+		return true
+	}
+
+	if soap.IsSoapFault(err) {
+		switch soap.ToSoapFault(err).VimFault().(type) {
+		case types.HostCommunication, *types.HostCommunication:
+			return true
+		}
+	}
+
+	return false
+}
+
+type retrier struct {
+	roundTripper soap.RoundTripper
+	policy       RetryPolicy
+}
+
+func methodName(req soap.HasFault) string {
+	t := reflect.TypeOf(req)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return strings.TrimSuffix(t.Name(), "Body")
+}
+
+// resetBody clears res before it is decoded into again, otherwise the fault of the previous attempt sticks.
+func resetBody(res soap.HasFault) {
+	v := reflect.ValueOf(res)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
+
+// RoundTrip implements soap.RoundTripper
+func (r *retrier) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	idempotent := r.policy.Idempotent
+	if idempotent == nil {
+		idempotent = IsIdempotent
+	}
+
+	backoff := r.policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := r.roundTripper.RoundTrip(ctx, req, res)
+		if err == nil || attempt >= r.policy.Attempts {
+			return err
+		}
+
+		if !isRejected(err) && !(isTransient(err) && idempotent(methodName(req))) {
+			return err
+		}
+
+		delay := backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		if backoff *= 2; r.policy.MaxBackoff != 0 && backoff > r.policy.MaxBackoff {
+			backoff = r.policy.MaxBackoff
+		}
+
+		resetBody(res)
+	}
+}
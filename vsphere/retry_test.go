@@ -0,0 +1,165 @@
+package vsphere
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"RetrievePropertiesEx", true},
+		{"FindByUuid", true},
+		{"QueryVirtualDiskUuid", true},
+		{"ListFilesInGuest", true},
+		{"ValidateCredentialsInGuest", true},
+		{"CurrentTime", true},
+		{"WaitForUpdatesEx", true},
+		{"InitiateFileTransferFromGuest", true},
+		{"InitiateFileTransferToGuest", false},
+		{"StartProgramInGuest", false},
+		{"PowerOnVM_Task", false},
+		{"CreateVM_Task", false},
+		{"Login", false},
+		// prefixes are case sensitive
+		{"retrieveProperties", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsIdempotent(tt.method); got != tt.want {
+			t.Errorf("IsIdempotent(%q) = %t, want %t", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestMethodName(t *testing.T) {
+	tests := []struct {
+		req  soap.HasFault
+		want string
+	}{
+		{&methods.RetrievePropertiesExBody{}, "RetrievePropertiesEx"},
+		{&methods.PowerOnVM_TaskBody{}, "PowerOnVM_Task"},
+		{&methods.StartProgramInGuestBody{}, "StartProgramInGuest"},
+		{&methods.CurrentTimeBody{}, "CurrentTime"},
+	}
+
+	for _, tt := range tests {
+		if got := methodName(tt.req); got != tt.want {
+			t.Errorf("methodName(%T) = %q, want %q", tt.req, got, tt.want)
+		}
+	}
+}
+
+// fakeRoundTripper fails with errs in turn, then succeeds. It leaves a fault in the response body of every
+// failed attempt, the way soap.Client decodes one, and records whether each attempt got a clean body.
+type fakeRoundTripper struct {
+	errs  []error
+	calls int
+	clean []bool
+}
+
+func (f *fakeRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	f.calls++
+	f.clean = append(f.clean, reflect.ValueOf(res).Elem().IsZero())
+
+	if len(f.errs) == 0 {
+		return nil
+	}
+
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+
+	if body, ok := res.(*methods.PowerOnVM_TaskBody); ok {
+		body.Fault_ = &soap.Fault{Code: "ServerFaultCode"}
+	}
+	if body, ok := res.(*methods.RetrievePropertiesExBody); ok {
+		body.Fault_ = &soap.Fault{Code: "ServerFaultCode"}
+	}
+
+	return err
+}
+
+func vimFault(fault types.AnyType) error {
+	f := &soap.Fault{Code: "ServerFaultCode"}
+	f.Detail.Fault = fault
+	return soap.WrapSoapFault(f)
+}
+
+func TestRetrierRoundTrip(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond * 2}
+	// what soap.Client returns when vCenter drops the connection
+	reset := &url.Error{Op: "Post", URL: "https://vcenter/sdk", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}
+
+	tests := []struct {
+		name      string
+		req       soap.HasFault
+		res       soap.HasFault
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", &methods.RetrievePropertiesExBody{}, &methods.RetrievePropertiesExBody{}, nil, 1, false},
+		{"idempotent after network error", &methods.RetrievePropertiesExBody{}, &methods.RetrievePropertiesExBody{}, []error{reset, io.EOF}, 3, false},
+		{"not idempotent after network error", &methods.PowerOnVM_TaskBody{}, &methods.PowerOnVM_TaskBody{}, []error{reset}, 1, true},
+		{"task in progress", &methods.PowerOnVM_TaskBody{}, &methods.PowerOnVM_TaskBody{}, []error{vimFault(&types.TaskInProgress{})}, 2, false},
+		{"concurrent access", &methods.PowerOnVM_TaskBody{}, &methods.PowerOnVM_TaskBody{}, []error{vimFault(types.ConcurrentAccess{})}, 2, false},
+		{"host communication", &methods.RetrievePropertiesExBody{}, &methods.RetrievePropertiesExBody{}, []error{vimFault(&types.HostCommunication{})}, 2, false},
+		{"permanent fault", &methods.RetrievePropertiesExBody{}, &methods.RetrievePropertiesExBody{}, []error{vimFault(&types.NotFound{})}, 1, true},
+		{"attempts exhausted", &methods.RetrievePropertiesExBody{}, &methods.RetrievePropertiesExBody{}, []error{reset, reset, reset, reset}, 3, true},
+	}
+
+	for _, tt := range tests {
+		fake := &fakeRoundTripper{errs: tt.errs}
+		r := &retrier{roundTripper: fake, policy: policy}
+
+		err := r.RoundTrip(context.Background(), tt.req, tt.res)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: RoundTrip error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+
+		if fake.calls != tt.wantCalls {
+			t.Errorf("%s: %d attempts, want %d", tt.name, fake.calls, tt.wantCalls)
+		}
+
+		for i, clean := range fake.clean {
+			if !clean {
+				t.Errorf("%s: attempt %d got the response body of the previous one", tt.name, i+1)
+			}
+		}
+	}
+}
+
+func TestRetrierCancelBackoff(t *testing.T) {
+	fake := &fakeRoundTripper{errs: []error{io.EOF}}
+	r := &retrier{roundTripper: fake, policy: RetryPolicy{Attempts: 3, InitialBackoff: time.Hour}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	start := time.Now()
+	if err := r.RoundTrip(ctx, &methods.RetrievePropertiesExBody{}, &methods.RetrievePropertiesExBody{}); err == nil {
+		t.Error("RoundTrip succeeded after the context was cancelled")
+	}
+
+	if d := time.Since(start); d > time.Second*5 {
+		t.Errorf("RoundTrip waited %s after the context was cancelled", d)
+	}
+
+	if fake.calls != 1 {
+		t.Errorf("%d attempts, want 1", fake.calls)
+	}
+}
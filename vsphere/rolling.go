@@ -0,0 +1,117 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RollingOptions controls how InvokeRolling moves a job through a set of targets.
+type RollingOptions struct {
+	// BatchSize is the number of targets run at the same time. Defaults to 1.
+	BatchSize int
+	// MaxFailurePercent stops the rollout after the batch that pushes the share of failed
+	// targets, out of all targets of the rollout, above this value. 0 stops on the first failure.
+	MaxFailurePercent float64
+	// BeforeBatch is called before each batch, e.g. to drain the VMs or put their hosts into maintenance.
+	// An error stops the rollout before the batch runs.
+	BeforeBatch func(ctx context.Context, batch []Target) error
+	// AfterBatch is called after each batch with its results, e.g. to take hosts out of maintenance again.
+	AfterBatch func(ctx context.Context, batch []Target, results Results) error
+	// DryRun only sends the planned batches to data.
+	DryRun bool
+}
+
+// PlanBatches splits targets into batches of size, keeping their order.
+func PlanBatches(targets []Target, size int) [][]Target {
+	if size <= 0 {
+		size = 1
+	}
+
+	var batches [][]Target
+
+	for len(targets) > size {
+		batches = append(batches, targets[:size])
+		targets = targets[size:]
+	}
+
+	if len(targets) > 0 {
+		batches = append(batches, targets)
+	}
+
+	return batches
+}
+
+// InvokeRolling runs script on targets batch by batch using InvokeParallel. It returns the results of every target
+// that was run, and an error when the failure threshold was crossed or a batch hook failed. Like for InvokeParallel,
+// data may be nil.
+func InvokeRolling(ctx context.Context, targets []Target, data chan string, script string, opts RollingOptions) (Results, error) {
+	if data != nil {
+		defer close(data)
+	}
+
+	batches := PlanBatches(targets, opts.BatchSize)
+
+	if opts.DryRun {
+		if data == nil {
+			return nil, nil
+		}
+
+		for i, batch := range batches {
+			names := make([]string, len(batch))
+			for j, t := range batch {
+				names[j] = t.Name
+			}
+			data <- fmt.Sprintf("batch %d/%d: %s\n", i+1, len(batches), strings.Join(names, ", "))
+		}
+
+		return nil, nil
+	}
+
+	var results Results
+
+	for i, batch := range batches {
+		if opts.BeforeBatch != nil {
+			if err := opts.BeforeBatch(ctx, batch); err != nil {
+				return results, fmt.Errorf("batch %d: %s", i+1, err)
+			}
+		}
+
+		var out chan string
+		done := make(chan struct{})
+
+		if data != nil {
+			out = make(chan string)
+			go func() {
+				defer close(done)
+				for s := range out {
+					data <- s
+				}
+			}()
+		} else {
+			close(done)
+		}
+
+		res := InvokeParallel(ctx, batch, out, ScriptJob(script), len(batch))
+		<-done
+
+		results = append(results, res...)
+
+		if opts.AfterBatch != nil {
+			if err := opts.AfterBatch(ctx, batch, res); err != nil {
+				return results, fmt.Errorf("batch %d: %s", i+1, err)
+			}
+		}
+
+		failed := len(results.Failed())
+		if failed > 0 && float64(failed)*100/float64(len(targets)) > opts.MaxFailurePercent {
+			return results, fmt.Errorf("batch %d: %d of %d targets failed, stopping: %s", i+1, failed, len(targets), results.Err())
+		}
+
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
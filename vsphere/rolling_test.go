@@ -0,0 +1,91 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanBatches(t *testing.T) {
+	targets := func(names ...string) []Target {
+		ts := make([]Target, len(names))
+		for i, name := range names {
+			ts[i].Name = name
+		}
+		return ts
+	}
+
+	names := func(batches [][]Target) [][]string {
+		var res [][]string
+		for _, batch := range batches {
+			var b []string
+			for _, t := range batch {
+				b = append(b, t.Name)
+			}
+			res = append(res, b)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name    string
+		targets []Target
+		size    int
+		want    [][]string
+	}{
+		{"empty", nil, 2, nil},
+		{"default size", targets("a", "b"), 0, [][]string{{"a"}, {"b"}}},
+		{"negative size", targets("a", "b"), -3, [][]string{{"a"}, {"b"}}},
+		{"even", targets("a", "b", "c", "d"), 2, [][]string{{"a", "b"}, {"c", "d"}}},
+		{"remainder", targets("a", "b", "c", "d", "e"), 2, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{"one batch", targets("a", "b", "c"), 3, [][]string{{"a", "b", "c"}}},
+		{"size above count", targets("a", "b"), 10, [][]string{{"a", "b"}}},
+	}
+
+	for _, tt := range tests {
+		if got := names(PlanBatches(tt.targets, tt.size)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: PlanBatches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInvokeRollingDryRun(t *testing.T) {
+	targets := []Target{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	opts := RollingOptions{BatchSize: 2, DryRun: true}
+
+	data := make(chan string)
+	done := make(chan []string)
+	go func() {
+		var lines []string
+		for line := range data {
+			lines = append(lines, line)
+		}
+		done <- lines
+	}()
+
+	if _, err := InvokeRolling(context.Background(), targets, data, "", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"batch 1/2: a, b\n", "batch 2/2: c\n"}
+	if lines := <-done; !reflect.DeepEqual(lines, want) {
+		t.Errorf("dry run = %q, want %q", lines, want)
+	}
+
+	// without data a dry run has nothing to report, but must not block
+	finished := make(chan error, 1)
+	go func() {
+		_, err := InvokeRolling(context.Background(), targets, nil, "", opts)
+		finished <- err
+	}()
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("dry run with nil data blocked")
+	}
+}
@@ -0,0 +1,74 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// containerTypes are the managed object types a container view can start at.
+var containerTypes = map[string]bool{
+	"Folder":                 true,
+	"Datacenter":             true,
+	"ComputeResource":        true,
+	"ClusterComputeResource": true,
+	"ResourcePool":           true,
+	"VirtualApp":             true,
+	"HostSystem":             true,
+}
+
+// WithScope limits the inventory functions to what is below scope instead of the whole inventory. scope is
+// the inventory path of a datacenter, folder, cluster, host or resource pool, e.g. "/dc1/vm/team-a", or its
+// MoRef in the "Type:value" form, e.g. "ResourcePool:resgroup-42".
+func WithScope(scope string) InventoryOption {
+	return func(o *inventoryOptions) {
+		o.scope = scope
+	}
+}
+
+// WithScopeRef is WithScope for a MoRef the caller already has.
+func WithScopeRef(ref types.ManagedObjectReference) InventoryOption {
+	return WithScope(ref.String())
+}
+
+// parseScopeRef parses scope as a MoRef of a container type; anything else is taken as an inventory path.
+func parseScopeRef(scope string) (types.ManagedObjectReference, bool) {
+	var ref types.ManagedObjectReference
+
+	if strings.HasPrefix(scope, "/") || !ref.FromString(scope) {
+		return ref, false
+	}
+
+	return ref, containerTypes[ref.Type]
+}
+
+// root returns the container the inventory functions start at, the root folder unless WithScope was given.
+func (o *inventoryOptions) root(ctx context.Context, c *vim25.Client) (types.ManagedObjectReference, error) {
+	if o.scope == "" {
+		return c.ServiceContent.RootFolder, nil
+	}
+
+	if ref, ok := parseScopeRef(o.scope); ok {
+		return ref, nil
+	}
+
+	elements, err := find.NewFinder(c).ManagedObjectList(ctx, o.scope)
+	if err != nil {
+		return types.ManagedObjectReference{}, fmt.Errorf("scope %s: %s", o.scope, err)
+	}
+
+	if len(elements) != 1 {
+		return types.ManagedObjectReference{}, fmt.Errorf("scope %s: matches %d objects", o.scope, len(elements))
+	}
+
+	ref := elements[0].Object.Reference()
+	if !containerTypes[ref.Type] {
+		return types.ManagedObjectReference{}, fmt.Errorf("scope %s: %s is not a container", o.scope, ref.Type)
+	}
+
+	return ref, nil
+}
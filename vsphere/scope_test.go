@@ -0,0 +1,124 @@
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestParseScopeRef(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  types.ManagedObjectReference
+		ok    bool
+	}{
+		{"ResourcePool:resgroup-42", types.ManagedObjectReference{Type: "ResourcePool", Value: "resgroup-42"}, true},
+		{"ClusterComputeResource:domain-c7", types.ManagedObjectReference{Type: "ClusterComputeResource", Value: "domain-c7"}, true},
+		{"Folder:group-v3", types.ManagedObjectReference{Type: "Folder", Value: "group-v3"}, true},
+		{"HostSystem:host-21", types.ManagedObjectReference{Type: "HostSystem", Value: "host-21"}, true},
+		// a MoRef, but not of a container
+		{"VirtualMachine:vm-42", types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"}, false},
+		// inventory paths, including one with a colon in a name
+		{"/dc1/vm/team-a", types.ManagedObjectReference{}, false},
+		{"/dc1/vm/Folder:group-v3", types.ManagedObjectReference{}, false},
+		{"team-a", types.ManagedObjectReference{}, false},
+		{"", types.ManagedObjectReference{}, false},
+	}
+
+	for _, tt := range tests {
+		ref, ok := parseScopeRef(tt.scope)
+		if ok != tt.ok || (tt.ok && ref != tt.want) {
+			t.Errorf("parseScopeRef(%q) = %v, %t, want %v, %t", tt.scope, ref, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestScopeRoot(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		cluster := simulator.Map.Any("ClusterComputeResource").Reference()
+
+		tests := []struct {
+			scope   string
+			want    types.ManagedObjectReference
+			wantErr bool
+		}{
+			{"", c.ServiceContent.RootFolder, false},
+			{cluster.String(), cluster, false},
+			{"/DC0/host/DC0_C0", cluster, false},
+			{"/DC0/vm/DC0_H0_VM0", types.ManagedObjectReference{}, true},
+			{"/DC0/host/missing", types.ManagedObjectReference{}, true},
+		}
+
+		for _, tt := range tests {
+			o := inventoryOptions{}
+			WithScope(tt.scope)(&o)
+
+			ref, err := o.root(ctx, c)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("root(%q) = %v, want an error", tt.scope, ref)
+				}
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("root(%q): %s", tt.scope, err)
+			} else if ref != tt.want {
+				t.Errorf("root(%q) = %v, want %v", tt.scope, ref, tt.want)
+			}
+		}
+	})
+}
+
+func TestGetComputeClustersScope(t *testing.T) {
+	m := simulator.VPX()
+	m.Datacenter = 2
+
+	err := m.Run(func(ctx context.Context, c *vim25.Client) error {
+		tests := []struct {
+			pattern string
+			opts    []InventoryOption
+			want    []string
+		}{
+			{"*", nil, []string{"DC0_C0", "DC1_C0"}},
+			{"*", []InventoryOption{WithScope("/DC1")}, []string{"DC1_C0"}},
+			{"DC0_*", []InventoryOption{WithScope("/DC1/host")}, nil},
+			{"missing", nil, nil},
+		}
+
+		for _, tt := range tests {
+			clusters, err := GetComputeClusters(ctx, c, tt.pattern, tt.opts...)
+			if err != nil {
+				t.Errorf("%s: GetComputeClusters: %s", tt.pattern, err)
+				continue
+			}
+
+			var got []string
+			for _, cluster := range clusters {
+				got = append(got, cluster.Name)
+				if len(cluster.Hosts) == 0 || cluster.ResourcePool == nil {
+					t.Errorf("%s: cluster %s without hosts or resource pool", tt.pattern, cluster.Name)
+				}
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: clusters %v, want %v", tt.pattern, got, tt.want)
+			}
+		}
+
+		if _, err := GetComputeClusters(ctx, c, "*", WithScope("/DC9")); err == nil {
+			t.Error("GetComputeClusters accepted a missing scope")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+package vsphere
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/vmware/govmomi/session/cache"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// WithSessionCache saves the session cookie in dir and reuses it on the next NewClient while the
+// session is still valid, the same way govc does. An empty dir uses govc's $HOME/.govmomi/sessions.
+func WithSessionCache(dir string) ClientOption {
+	return func(o *clientOptions) {
+		o.sessionCache = true
+		o.sessionDir = dir
+	}
+}
+
+// WithKeepAlive sends a request every idle interval so the session does not time out while
+// the client sits waiting, e.g. polling a long running guest process.
+func WithKeepAlive(idle time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.keepAlive = idle
+	}
+}
+
+// reauth logs in again and retries the request once when vCenter reports the session as NotAuthenticated.
+type reauth struct {
+	roundTripper soap.RoundTripper
+	login        func(context.Context, *vim25.Client) error
+	client       *vim25.Client
+	session      *cache.Session
+
+	mu sync.Mutex
+}
+
+func isNotAuthenticated(err error) bool {
+	if !soap.IsSoapFault(err) {
+		return false
+	}
+
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.NotAuthenticated, *types.NotAuthenticated:
+		return true
+	}
+
+	return false
+}
+
+// RoundTrip implements soap.RoundTripper
+func (r *reauth) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	err := r.roundTripper.RoundTrip(ctx, req, res)
+	if !isNotAuthenticated(err) {
+		return err
+	}
+
+	if lerr := r.relogin(ctx); lerr != nil {
+		return err
+	}
+
+	resetBody(res)
+
+	return r.roundTripper.RoundTrip(ctx, req, res)
+}
+
+func (r *reauth) relogin(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// login through the wrapped round tripper so this does not recurse into reauth
+	c := *r.client
+	c.RoundTripper = r.roundTripper
+
+	if err := r.login(ctx, &c); err != nil {
+		return err
+	}
+
+	if r.session != nil {
+		if err := r.session.Save(r.client); err != nil {
+			logging.Warn(ctx, "saving session failed", logging.Fields{"error": err})
+		}
+	}
+
+	return nil
+}
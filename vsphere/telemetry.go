@@ -0,0 +1,29 @@
+package vsphere
+
+import (
+	"context"
+
+	"github.com/roshankarande/utils/telemetry"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// WithTelemetry reports an OpenTelemetry span and call count and latency metrics for every SOAP call of the
+// client, including each retry, named after the SOAP method. See telemetry.Setup for exporting them.
+func WithTelemetry() ClientOption {
+	return func(o *clientOptions) {
+		o.telemetry = true
+	}
+}
+
+type instrumented struct {
+	roundTripper soap.RoundTripper
+}
+
+// RoundTrip implements soap.RoundTripper
+func (i *instrumented) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	ctx, op := telemetry.Start(ctx, "soap."+methodName(req))
+	err := i.roundTripper.RoundTrip(ctx, req, res)
+	op.End(err)
+
+	return err
+}
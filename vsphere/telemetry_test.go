@@ -0,0 +1,87 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanStatus is the name and status of a span, in the order the spans ended.
+type spanStatus struct {
+	name string
+	code codes.Code
+}
+
+func recordSpans() (*tracetest.InMemoryExporter, func() []spanStatus, func()) {
+	spans := tracetest.NewInMemoryExporter()
+	global.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+
+	ended := func() []spanStatus {
+		var s []spanStatus
+		for _, span := range spans.GetSpans() {
+			s = append(s, spanStatus{span.Name, span.StatusCode})
+		}
+		return s
+	}
+
+	return spans, ended, func() { global.SetTracerProvider(trace.NoopTracerProvider()) }
+}
+
+func TestTelemetrySOAPSpans(t *testing.T) {
+	spans, ended, stop := recordSpans()
+	defer stop()
+
+	cfg, stopSim := simulatorConfig(t, simulator.VPX(), "sim")
+	defer stopSim()
+
+	ctx := context.Background()
+
+	c, err := NewClient(ctx, cfg.URL, cfg.Username, cfg.Password, WithInsecure(), WithTelemetry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans.Reset()
+
+	if _, err = methods.GetCurrentTime(ctx, c.Client); err != nil {
+		t.Fatal(err)
+	}
+
+	// not found is not retried, so it is a single failed call
+	_, err = methods.PowerOnVM_Task(ctx, c.Client, &types.PowerOnVM_Task{This: types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-missing"}})
+	if err == nil {
+		t.Fatal("PowerOnVM_Task on a missing VM succeeded")
+	}
+
+	want := []spanStatus{{"soap.CurrentTime", codes.Unset}, {"soap.PowerOnVM_Task", codes.Error}}
+	if got := ended(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("spans %v, want %v", got, want)
+	}
+}
+
+func TestTelemetrySOAPSpansPerAttempt(t *testing.T) {
+	_, ended, stop := recordSpans()
+	defer stop()
+
+	// the retrier wraps the instrumented round tripper, as in newClient
+	fake := &fakeRoundTripper{errs: []error{vimFault(&types.TaskInProgress{})}}
+	r := &retrier{roundTripper: &instrumented{roundTripper: fake}, policy: RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+
+	if err := r.RoundTrip(context.Background(), &methods.PowerOnVM_TaskBody{}, &methods.PowerOnVM_TaskBody{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []spanStatus{{"soap.PowerOnVM_Task", codes.Error}, {"soap.PowerOnVM_Task", codes.Unset}}
+	if got := ended(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("spans %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package vsphere
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// ThumbprintSHA256 returns the SHA-256 thumbprint of cert in the colon separated hex form used by vSphere.
+func ThumbprintSHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// hostAddr adds the default https port to addr if missing.
+func hostAddr(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(strings.Trim(addr, "[]"), "443")
+	}
+	return addr
+}
+
+// tlsVerifier dials with the regular chain verification and falls back to the pinned thumbprint of the host, if any.
+// Hosts not pinned with WithThumbprint are looked up in the thumbprints known to client, where govmomi registers
+// the ESX hosts it is redirected to for guest file transfers. Unlike soap.Client's own thumbprint check it accepts
+// SHA-256 pins and reports what the server presented.
+type tlsVerifier struct {
+	config      *tls.Config
+	thumbprints map[string]string
+	client      *soap.Client
+}
+
+// thumbprint returns the pinned thumbprint of addr, empty if there is none.
+func (v *tlsVerifier) thumbprint(addr string) string {
+	if t := v.thumbprints[hostAddr(addr)]; t != "" {
+		return t
+	}
+
+	if v.client != nil {
+		return v.client.Thumbprint(addr)
+	}
+
+	return ""
+}
+
+type CertificateError struct {
+	Host     string
+	Err      error
+	Expected string
+	SHA1     string
+	SHA256   string
+}
+
+func (e *CertificateError) Error() string {
+	if e.Expected != "" {
+		return fmt.Sprintf("host %q thumbprint does not match %s, presented SHA-1 %s SHA-256 %s", e.Host, e.Expected, e.SHA1, e.SHA256)
+	}
+	return fmt.Sprintf("host %q certificate verification failed: %s, presented SHA-1 %s SHA-256 %s", e.Host, e.Err, e.SHA1, e.SHA256)
+}
+
+// isVerificationError reports whether err is a failed chain or hostname verification. Since Go 1.20 tls.Dial
+// wraps these in a *tls.CertificateVerificationError, so the x509 errors are looked for in the chain.
+func isVerificationError(err error) bool {
+	var (
+		authority x509.UnknownAuthorityError
+		hostname  x509.HostnameError
+		invalid   x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid)
+}
+
+func (v *tlsVerifier) dialTLS(network, addr string) (net.Conn, error) {
+	conn, err := tls.Dial(network, addr, v.config)
+	if err == nil {
+		return conn, nil
+	}
+
+	if !isVerificationError(err) {
+		return nil, err
+	}
+
+	config := v.config.Clone()
+	config.InsecureSkipVerify = true
+
+	conn, derr := tls.Dial(network, addr, config)
+	if derr != nil {
+		return nil, err
+	}
+
+	cert := conn.ConnectionState().PeerCertificates[0]
+	presented := &CertificateError{
+		Host:   addr,
+		Err:    err,
+		SHA1:   soap.ThumbprintSHA1(cert),
+		SHA256: ThumbprintSHA256(cert),
+	}
+
+	expected := strings.ToUpper(v.thumbprint(addr))
+	if expected == "" {
+		_ = conn.Close()
+		return nil, presented
+	}
+
+	if expected != presented.SHA1 && expected != presented.SHA256 {
+		_ = conn.Close()
+		presented.Expected = expected
+		return nil, presented
+	}
+
+	return conn, nil
+}
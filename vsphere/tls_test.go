@@ -0,0 +1,134 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// newTLSServer starts a TLS server with a self-signed certificate that does not log aborted handshakes.
+func newTLSServer() *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	return srv
+}
+
+func TestTLSVerifierThumbprint(t *testing.T) {
+	srv := newTLSServer()
+	defer srv.Close()
+
+	addr := strings.TrimPrefix(srv.URL, "https://")
+	thumbprint := ThumbprintSHA256(srv.Certificate())
+
+	sha1 := soap.ThumbprintSHA1(srv.Certificate())
+	wrong := strings.Repeat("AB:", 31) + "AB"
+
+	tests := []struct {
+		name       string
+		pin        string
+		clientPin  string
+		wantErr    bool
+		wantExpect string
+	}{
+		{"pinned", thumbprint, "", false, ""},
+		{"pinned lowercase", strings.ToLower(thumbprint), "", false, ""},
+		{"pinned sha1", sha1, "", false, ""},
+		{"wrong pin", wrong, "", true, wrong},
+		{"no pin", "", "", true, ""},
+		{"known to client sha1", "", sha1, false, ""},
+		{"known to client sha256", "", thumbprint, false, ""},
+		{"wrong pin known to client", "", wrong, true, wrong},
+		{"pin before client", wrong, sha1, true, wrong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := soap.NewClient(&url.URL{Scheme: "https", Host: "vcenter"}, false)
+			v := &tlsVerifier{config: &tls.Config{}, thumbprints: map[string]string{}, client: sc}
+			if tt.pin != "" {
+				v.thumbprints[hostAddr(addr)] = tt.pin
+			}
+			if tt.clientPin != "" {
+				sc.SetThumbprint(addr, tt.clientPin)
+			}
+
+			conn, err := v.dialTLS("tcp", addr)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("dialTLS: %s", err)
+				}
+				_ = conn.Close()
+				return
+			}
+
+			cerr, ok := err.(*CertificateError)
+			if !ok {
+				t.Fatalf("dialTLS error = %T %v, want *CertificateError", err, err)
+			}
+
+			if cerr.SHA256 != thumbprint {
+				t.Errorf("presented SHA256 = %s, want %s", cerr.SHA256, thumbprint)
+			}
+
+			if cerr.Expected != tt.wantExpect {
+				t.Errorf("Expected = %q, want %q", cerr.Expected, tt.wantExpect)
+			}
+
+			if !strings.Contains(cerr.Error(), thumbprint) {
+				t.Errorf("error %q does not report the presented thumbprint", cerr.Error())
+			}
+		})
+	}
+}
+
+// TestTLSVerifierTransferHost checks that hosts govmomi registers with SetThumbprint, such as the ESX host
+// of a guest file transfer, are reachable when only vCenter is pinned with WithThumbprint.
+func TestTLSVerifierTransferHost(t *testing.T) {
+	m := simulator.VPX()
+	defer m.Remove()
+
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Service.TLS = new(tls.Config)
+
+	s := m.Service.NewServer()
+	defer s.Close()
+
+	esx := newTLSServer()
+	defer esx.Close()
+
+	password, _ := s.URL.User.Password()
+	ctx := context.Background()
+
+	c, err := NewClient(ctx, s.URL.String(), s.URL.User.Username(), password,
+		WithThumbprint(s.URL.Host, ThumbprintSHA256(s.Certificate())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	esxHost := strings.TrimPrefix(esx.URL, "https://")
+
+	if _, err = c.Client.Client.Get(esx.URL); err == nil {
+		t.Fatal("unpinned host accepted")
+	}
+
+	c.Client.SetThumbprint(esxHost, soap.ThumbprintSHA1(esx.Certificate()))
+
+	res, err := c.Client.Client.Get(esx.URL)
+	if err != nil {
+		t.Fatalf("host pinned with SetThumbprint: %s", err)
+	}
+	_ = res.Body.Close()
+}
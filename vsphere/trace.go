@@ -0,0 +1,111 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/roshankarande/utils/logging"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// WithSOAPTrace logs every SOAP request and response body at debug level, with passwords,
+// tokens and session ids redacted.
+func WithSOAPTrace() ClientOption {
+	return func(o *clientOptions) {
+		o.trace = true
+	}
+}
+
+// secretElement matches the names of elements whose content is redacted. envVariables is included as
+// toolbox.Client.RunElevated passes the task password through the environment.
+var secretElement = regexp.MustCompile(`^(?:\w*(?:[pP]assword|[sS]ecret|[tT]oken|[sS]essionId|[sS]essionID)|envVariables)$`)
+
+// RedactSOAP replaces the content of password, token, secret and session id elements in body, including any
+// elements nested in them. A body that is not well-formed XML is redacted entirely.
+func RedactSOAP(body string) string {
+	d := xml.NewDecoder(strings.NewReader(body))
+
+	var (
+		b     strings.Builder
+		last  int64 // end of what was copied to b
+		start int64 // end of the start tag of the secret element being skipped
+		depth int   // nesting within that element, 0 outside of secrets
+	)
+
+	for {
+		offset := d.InputOffset()
+
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return redacted
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth > 0 {
+				depth++
+			} else if secretElement.MatchString(t.Name.Local) {
+				depth, start = 1, d.InputOffset()
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 && offset > start {
+				b.WriteString(body[last:start])
+				b.WriteString(redacted)
+				last = offset
+			}
+		}
+	}
+
+	if depth > 0 {
+		return redacted
+	}
+
+	b.WriteString(body[last:])
+
+	return b.String()
+}
+
+func marshalRedacted(v interface{}) string {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+
+	return RedactSOAP(string(b))
+}
+
+type tracer struct {
+	roundTripper soap.RoundTripper
+}
+
+// RoundTrip implements soap.RoundTripper
+func (t *tracer) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	method := methodName(req)
+
+	logging.Debug(ctx, "soap request", logging.Fields{"method": method, "body": marshalRedacted(req)})
+
+	start := time.Now()
+	err := t.roundTripper.RoundTrip(ctx, req, res)
+
+	fields := logging.Fields{"method": method, "duration": time.Since(start)}
+	if err != nil {
+		fields["error"] = err
+	} else {
+		fields["body"] = marshalRedacted(res)
+	}
+
+	logging.Debug(ctx, "soap response", fields)
+
+	return err
+}
@@ -0,0 +1,63 @@
+package vsphere
+
+import "testing"
+
+func TestRedactSOAP(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			"leaf",
+			`<Login><userName>admin</userName><password>hunter2</password></Login>`,
+			`<Login><userName>admin</userName><password>` + redacted + `</password></Login>`,
+		},
+		{
+			"attributes",
+			`<auth xsi:type="NamePasswordAuthentication"><password xsi:type="xsd:string">hunter2</password></auth>`,
+			`<auth xsi:type="NamePasswordAuthentication"><password xsi:type="xsd:string">` + redacted + `</password></auth>`,
+		},
+		{
+			"nested",
+			`<identity><adminPassword><value>hunter2</value><plainText>true</plainText></adminPassword><name>x</name></identity>`,
+			`<identity><adminPassword>` + redacted + `</adminPassword><name>x</name></identity>`,
+		},
+		{
+			"nested same name",
+			`<password><password>hunter2</password></password><b>ok</b>`,
+			`<password>` + redacted + `</password><b>ok</b>`,
+		},
+		{
+			"session and token",
+			`<r><sessionId>52a1</sessionId><samlToken><Assertion>x</Assertion></samlToken></r>`,
+			`<r><sessionId>` + redacted + `</sessionId><samlToken>` + redacted + `</samlToken></r>`,
+		},
+		{
+			"environment",
+			`<spec><programPath>p</programPath><envVariables>GOVMOMI_TASK_PASSWORD=hunter2</envVariables></spec>`,
+			`<spec><programPath>p</programPath><envVariables>` + redacted + `</envVariables></spec>`,
+		},
+		{
+			"empty",
+			`<r><password/><password></password></r>`,
+			`<r><password/><password></password></r>`,
+		},
+		{
+			"no secrets",
+			`<RetrieveProperties><specSet><pathSet>name</pathSet></specSet></RetrieveProperties>`,
+			`<RetrieveProperties><specSet><pathSet>name</pathSet></specSet></RetrieveProperties>`,
+		},
+		{
+			"malformed",
+			`<r><password>hunter2</r>`,
+			redacted,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := RedactSOAP(tt.body); got != tt.want {
+			t.Errorf("%s: RedactSOAP() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package vsphere
 import (
 	"context"
 	"fmt"
+	"github.com/roshankarande/utils/telemetry"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
@@ -10,14 +11,34 @@ import (
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
-	"reflect"
+	"go.opentelemetry.io/otel/label"
+	"net"
+	"regexp"
+	"strings"
 )
 
-func GetVirtualMachines(ctx context.Context, c *vim25.Client, namepattern string) ([]mo.VirtualMachine, error) {
+// GetVirtualMachines returns the VMs whose name matches namepattern, with the properties selected by opts,
+// PropertiesFull by default. No match is not an error.
+func GetVirtualMachines(ctx context.Context, c *vim25.Client, namepattern string, opts ...InventoryOption) ([]mo.VirtualMachine, error) {
+	var o inventoryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ps, err := o.propertiesFor("VirtualMachine")
+	if err != nil {
+		return nil, err
+	}
+
 	// Create view of VirtualMachine objects
 	m := view.NewManager(c)
 
-	v, err := m.CreateContainerView(ctx, c.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	root, err := o.root(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := m.CreateContainerView(ctx, root, []string{"VirtualMachine"}, true)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +51,7 @@ func GetVirtualMachines(ctx context.Context, c *vim25.Client, namepattern string
 
 	//err = v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"summary","guest.ipAddress","datastore","network"}, &vms)
 	//err = v.RetrieveWithFilter(ctx, []string{"VirtualMachine"}, []string{"summary","guest.ipAddress","datastore","network"}, &vms,property.Filter{"name": namepattern})
-	err = v.RetrieveWithFilter(ctx, []string{"VirtualMachine"}, []string{"summary", "guest", "datastore", "network", "runtime", "guestHeartbeatStatus", "storage", "config"}, &vms, property.Filter{"name": namepattern})
+	err = retrieveByName(ctx, v, "VirtualMachine", ps, namepattern, &vms)
 
 	if err != nil {
 		return nil, err
@@ -48,98 +69,171 @@ func GetVirtualMachines(ctx context.Context, c *vim25.Client, namepattern string
 	return vms, nil
 }
 
-func GetVirtualMachineDevices(ctx context.Context, c *vim25.Client, name string) (object.VirtualDeviceList, error) {
-
-	vm, err := find.NewFinder(c).VirtualMachine(ctx, name)
+// diskAndNICs selects the disks and network adapters of any adapter type from devices.
+func diskAndNICs(devices object.VirtualDeviceList) object.VirtualDeviceList {
+	return devices.Select(func(d types.BaseVirtualDevice) bool {
+		switch d.(type) {
+		case *types.VirtualDisk, types.BaseVirtualEthernetCard:
+			return true
+		}
+		return false
+	})
+}
 
+// GetVirtualMachineDevices returns the disks and network adapters of the VM found by FindVM for name.
+func GetVirtualMachineDevices(ctx context.Context, c *vim25.Client, name string) (object.VirtualDeviceList, error) {
+	vm, err := FindVM(ctx, c, name)
 	if err != nil {
 		return nil, err
 	}
 
-	var vms []mo.VirtualMachine
-
-	vm.Properties(ctx,vm.Reference(),[]string{"summary", "guest"},&vms)
-
-	var virtualDevicesFiltered []types.BaseVirtualDevice
-	devices, _ := vm.Device(ctx)
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("devices of %s: %s", name, err)
+	}
 
-	for _, d := range devices {
-		switch d.(type) {
-		case *types.VirtualDisk:
-			virtualDevicesFiltered = append(virtualDevicesFiltered, d)
-			disk := d.(*types.VirtualDisk)
-			info := d.GetVirtualDevice().Backing.(*types.VirtualDiskFlatVer2BackingInfo)
-			// info.EagerlyScrub
-			fmt.Println("[*types.VirtualDisk]", info.Datastore, info.FileName, *info.ThinProvisioned, info.DiskMode, disk.Key, disk.ControllerKey, *disk.UnitNumber, disk.DeviceInfo.GetDescription().Summary, disk.DeviceInfo.GetDescription().Label, disk.CapacityInKB/(1024*1024))
-
-		case *types.VirtualVmxnet3:
-			virtualDevicesFiltered = append(virtualDevicesFiltered, d)
-			vmxnet3Adapter := d.(*types.VirtualVmxnet3)
-			fmt.Println("[*types.VirtualVmxnet3]", vmxnet3Adapter.Connectable.Connected, vmxnet3Adapter.Connectable.StartConnected, vmxnet3Adapter.MacAddress, *vmxnet3Adapter.UnitNumber, vmxnet3Adapter.ControllerKey)
-
-		default:
-			//fmt.Println("Neither Network Nor Disk")
-			fmt.Printf("[%v]\n", reflect.TypeOf(d))
+	return diskAndNICs(devices), nil
+}
 
-		}
+// GetVirtualMachineDeviceInventory returns all devices of the VM found by FindVM for name as a DeviceInventory.
+func GetVirtualMachineDeviceInventory(ctx context.Context, c *vim25.Client, name string) (*DeviceInventory, error) {
+	vm, err := FindVM(ctx, c, name)
+	if err != nil {
+		return nil, err
+	}
 
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("devices of %s: %s", name, err)
 	}
 
-	return object.VirtualDeviceList(virtualDevicesFiltered), nil
+	return NewDeviceInventory(devices), nil
 }
 
 type VMInfo struct {
 	VirtualMachine mo.VirtualMachine
+	// DeviceList holds the disks and network adapters, Devices all devices in typed form.
 	DeviceList object.VirtualDeviceList
+	Devices    *DeviceInventory
 	Datastores []mo.Datastore
 	Networks []mo.Network
+	// HostSystem is left zero for VMs without a host, such as orphaned VMs.
 	HostSystem mo.HostSystem
 }
 
-func GetVM(ctx context.Context, c *vim25.Client, name string) (*VMInfo, error)  {
+// VMInfoError lists the parts of a VMInfo that GetVM could not retrieve. The VMInfo returned along with it
+// holds everything else.
+type VMInfoError []error
+
+func (e VMInfoError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FindVM looks up a VM by MoRef ("VirtualMachine:vm-42"), BIOS UUID, instance UUID, IP address as reported
+// by VMware Tools, or inventory path or name, in that order. A UUID or IP that no VM reports is tried as a
+// name as well.
+func FindVM(ctx context.Context, c *vim25.Client, id string) (*object.VirtualMachine, error) {
+	var ref types.ManagedObjectReference
+	if ref.FromString(id) && ref.Type == "VirtualMachine" {
+		return object.NewVirtualMachine(c, ref), nil
+	}
+
+	si := object.NewSearchIndex(c)
+
+	var (
+		found object.Reference
+		err   error
+	)
+
+	switch {
+	case uuidPattern.MatchString(id):
+		for _, instance := range []bool{false, true} {
+			instance := instance
+			found, err = si.FindByUuid(ctx, nil, id, true, &instance)
+			if err != nil || found != nil {
+				break
+			}
+		}
+	case net.ParseIP(id) != nil:
+		found, err = si.FindByIp(ctx, nil, id, true)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("finding vm %s: %s", id, err)
+	}
+
+	if found != nil {
+		return object.NewVirtualMachine(c, found.Reference()), nil
+	}
+
+	return find.NewFinder(c).VirtualMachine(ctx, id)
+}
+
+// GetVM returns the VM found by FindVM for name along with its devices, datastores, networks and host.
+// If any of those cannot be retrieved the rest is still returned, together with a VMInfoError.
+func GetVM(ctx context.Context, c *vim25.Client, name string) (_ *VMInfo, err error)  {
+	ctx, op := telemetry.Start(ctx, "vsphere.GetVM")
+	defer func() { op.End(err) }()
+
+	op.SetAttributes(label.String("vm", name))
 
 	var vmInfo VMInfo
 
-	vm, err := find.NewFinder(c).VirtualMachine(ctx, name)
+	vm, err := FindVM(ctx, c, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = vm.Properties(ctx,vm.Reference(),[]string{"name", "summary", "guest", "datastore", "network", "runtime", "guestHeartbeatStatus", "storage", "config"},&vmInfo.VirtualMachine)
 
 	if err != nil {
 		return nil, err
 	}
 
-	vm.Properties(ctx,vm.Reference(),[]string{"summary", "guest", "datastore", "network", "runtime", "guestHeartbeatStatus", "storage", "config"},&vmInfo.VirtualMachine)
+	var errs VMInfoError
 
-	var virtualDevicesFiltered []types.BaseVirtualDevice
-	devices, _ := vm.Device(ctx)
+	devices, err := vm.Device(ctx)
 
-	for _, d := range devices {
-		switch d.(type) {
-		case *types.VirtualDisk:
-			virtualDevicesFiltered = append(virtualDevicesFiltered, d)
-			disk := d.(*types.VirtualDisk)
-			info := d.GetVirtualDevice().Backing.(*types.VirtualDiskFlatVer2BackingInfo)
-			// info.EagerlyScrub
-			fmt.Println("[*types.VirtualDisk]", info.Datastore, info.FileName, *info.ThinProvisioned, info.DiskMode, disk.Key, disk.ControllerKey, *disk.UnitNumber, disk.DeviceInfo.GetDescription().Summary, disk.DeviceInfo.GetDescription().Label, disk.CapacityInKB/(1024*1024))
-
-		case *types.VirtualVmxnet3:
-			virtualDevicesFiltered = append(virtualDevicesFiltered, d)
-			vmxnet3Adapter := d.(*types.VirtualVmxnet3)
-			fmt.Println("[*types.VirtualVmxnet3]", vmxnet3Adapter.Connectable.Connected, vmxnet3Adapter.Connectable.StartConnected, vmxnet3Adapter.MacAddress, *vmxnet3Adapter.UnitNumber, vmxnet3Adapter.ControllerKey)
-
-		default:
-			//fmt.Println("Neither Network Nor Disk")
-			fmt.Printf("[%v]\n", reflect.TypeOf(d))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("devices: %s", err))
+	} else {
+		vmInfo.DeviceList = diskAndNICs(devices)
+		vmInfo.Devices = NewDeviceInventory(devices)
+	}
+
+	pc := property.DefaultCollector(c)
+
+	// templates and orphaned VMs can be without datastores, networks or a host
+	if len(vmInfo.VirtualMachine.Datastore) != 0 {
+		if err = pc.Retrieve(ctx, vmInfo.VirtualMachine.Datastore, []string{"name"}, &vmInfo.Datastores); err != nil {
+			errs = append(errs, fmt.Errorf("datastores: %s", err))
 		}
 	}
 
-	vmInfo.DeviceList = virtualDevicesFiltered
+	if len(vmInfo.VirtualMachine.Network) != 0 {
+		if err = pc.Retrieve(ctx, vmInfo.VirtualMachine.Network, []string{"name"}, &vmInfo.Networks); err != nil {
+			errs = append(errs, fmt.Errorf("networks: %s", err))
+		}
+	}
 
-	pc := property.DefaultCollector(c)
+	if host := vmInfo.VirtualMachine.Runtime.Host; host != nil {
+		if err = pc.RetrieveOne(ctx, *host, []string{"name", "parent"}, &vmInfo.HostSystem); err != nil {
+			errs = append(errs, fmt.Errorf("host: %s", err))
+		}
+	}
 
-	// see if you would want to check for Errors...
-	pc.Retrieve(ctx,vmInfo.VirtualMachine.Datastore , []string{"name"}, &vmInfo.Datastores)
-	pc.Retrieve(ctx,vmInfo.VirtualMachine.Network,[]string{"name"},&vmInfo.Networks)
-	pc.Retrieve(ctx,[]types.ManagedObjectReference{*vmInfo.VirtualMachine.Runtime.Host},[]string{"name"},&vmInfo.HostSystem)
+	if len(errs) != 0 {
+		return &vmInfo, errs
+	}
 
-	return &vmInfo,nil
+	return &vmInfo, nil
 
 }
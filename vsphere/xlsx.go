@@ -0,0 +1,93 @@
+package vsphere
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// xlsxParts are the fixed parts of a workbook with a single sheet. Cells are written as inline strings, so
+// no shared string table or styles are needed.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="inventory" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func (t *Table) writeXLSX(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	for _, part := range xlsxParts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]interface{}, len(t.Header))
+	for i, h := range t.Header {
+		header[i] = h
+	}
+
+	for _, row := range append([][]interface{}{header}, t.Rows...) {
+		buf.WriteString("<row>")
+		for _, v := range row {
+			writeXLSXCell(&buf, v)
+		}
+		buf.WriteString("</row>")
+	}
+
+	buf.WriteString("</sheetData></worksheet>")
+
+	if _, err = buf.WriteTo(f); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+func writeXLSXCell(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("<c/>")
+	case int64:
+		buf.WriteString("<c><v>" + strconv.FormatInt(v, 10) + "</v></c>")
+	case float64:
+		buf.WriteString("<c><v>" + strconv.FormatFloat(v, 'f', -1, 64) + "</v></c>")
+	case bool:
+		b := "0"
+		if v {
+			b = "1"
+		}
+		buf.WriteString(`<c t="b"><v>` + b + "</v></c>")
+	default:
+		buf.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		_ = xml.EscapeText(buf, []byte(formatCell(v)))
+		buf.WriteString("</t></is></c>")
+	}
+}